@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrAccountNotFound is returned by AccountStore.Get and Update when no
+// account exists for the given id.
+var ErrAccountNotFound = errors.New("account not found")
+
+// ErrVersionMismatch is returned from an AccountStore.Update callback
+// when the caller's If-Match header doesn't match the account's current
+// version.
+var ErrVersionMismatch = errors.New("account version mismatch")
+
+// ErrAccountFrozen is returned from an AccountStore.Update callback when
+// the account is frozen and the requested mutation isn't allowed.
+var ErrAccountFrozen = errors.New("account is frozen")
+
+// AccountStore guards the accounts map behind a RWMutex, since
+// deposit/withdraw/transfer/freeze are all reachable concurrently from
+// the HTTP server. Every account also carries a monotonically
+// increasing version, bumped on each successful Update, which handlers
+// expose as an ETag and enforce via If-Match for optimistic concurrency.
+type AccountStore struct {
+	mu       sync.RWMutex
+	accounts map[string]*Account
+}
+
+// NewAccountStore wraps seed, taking ownership of it.
+func NewAccountStore(seed map[string]*Account) *AccountStore {
+	return &AccountStore{accounts: seed}
+}
+
+// Get returns a copy of the account identified by id.
+func (s *AccountStore) Get(id string) (Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.accounts[id]
+	if !ok {
+		return Account{}, ErrAccountNotFound
+	}
+	return *a, nil
+}
+
+// List returns a copy of every account.
+func (s *AccountStore) List() []Account {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Account, 0, len(s.accounts))
+	for _, a := range s.accounts {
+		out = append(out, *a)
+	}
+	return out
+}
+
+// Update applies fn to the account identified by id under an exclusive
+// lock and increments its version once fn returns nil. This gives
+// callers a single critical section in which to compare the account's
+// current version against a client-supplied If-Match before mutating
+// it. fn must not retain the *Account it's given past its own return.
+func (s *AccountStore) Update(id string, fn func(*Account) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.accounts[id]
+	if !ok {
+		return ErrAccountNotFound
+	}
+	if err := fn(a); err != nil {
+		return err
+	}
+	a.Version++
+	return nil
+}
+
+// UpdatePair applies fn to the two accounts identified by fromID and
+// toID under a single exclusive lock, bumping both of their versions
+// once fn returns nil. This is what transfer uses so the debit and the
+// credit land atomically under one critical section, rather than as
+// two separate Updates with a window for a concurrent mutation to land
+// on the destination in between. fn must not retain either *Account it's
+// given past its own return.
+func (s *AccountStore) UpdatePair(fromID, toID string, fn func(from, to *Account) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	from, ok := s.accounts[fromID]
+	if !ok {
+		return ErrAccountNotFound
+	}
+	to, ok := s.accounts[toID]
+	if !ok {
+		return ErrAccountNotFound
+	}
+	if err := fn(from, to); err != nil {
+		return err
+	}
+	from.Version++
+	to.Version++
+	return nil
+}
+
+// etagFor renders an account version as the quoted ETag value handlers
+// emit on GET and compare If-Match against on writes.
+func etagFor(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
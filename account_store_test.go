@@ -0,0 +1,97 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func newTestStore() *AccountStore {
+	return NewAccountStore(map[string]*Account{
+		"acc-1": {AccountID: "acc-1", Balance: 100, Currency: "USD"},
+		"acc-2": {AccountID: "acc-2", Balance: 0, Currency: "USD"},
+	})
+}
+
+func TestAccountStoreUpdateBumpsVersionOnSuccess(t *testing.T) {
+	store := newTestStore()
+	if err := store.Update("acc-1", func(a *Account) error {
+		a.Balance += 50
+		return nil
+	}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err := store.Get("acc-1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Version != 1 {
+		t.Errorf("Version = %d, want 1 after one successful Update", got.Version)
+	}
+	if got.Balance != 150 {
+		t.Errorf("Balance = %v, want 150", got.Balance)
+	}
+}
+
+func TestAccountStoreUpdateLeavesVersionOnError(t *testing.T) {
+	store := newTestStore()
+	if err := store.Update("acc-1", func(a *Account) error {
+		return ErrVersionMismatch
+	}); err != ErrVersionMismatch {
+		t.Fatalf("Update error = %v, want ErrVersionMismatch", err)
+	}
+	got, _ := store.Get("acc-1")
+	if got.Version != 0 {
+		t.Errorf("Version = %d, want 0 unchanged after a failed Update", got.Version)
+	}
+}
+
+func TestAccountStoreUpdatePairAtomicBalances(t *testing.T) {
+	store := newTestStore()
+	err := store.UpdatePair("acc-1", "acc-2", func(from, to *Account) error {
+		from.Balance -= 40
+		to.Balance += 40
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdatePair: %v", err)
+	}
+	from, _ := store.Get("acc-1")
+	to, _ := store.Get("acc-2")
+	if from.Balance != 60 || to.Balance != 40 {
+		t.Fatalf("balances after transfer = %v/%v, want 60/40", from.Balance, to.Balance)
+	}
+	if from.Version != 1 || to.Version != 1 {
+		t.Errorf("versions after transfer = %d/%d, want 1/1", from.Version, to.Version)
+	}
+}
+
+// TestAccountStoreUpdatePairIsSerialized drives many concurrent
+// transfers between the same two accounts and checks that the total
+// never drifts - the guarantee a single shared lock across both sides
+// is supposed to provide.
+func TestAccountStoreUpdatePairIsSerialized(t *testing.T) {
+	store := newTestStore()
+	const transfers = 200
+	var wg sync.WaitGroup
+	wg.Add(transfers)
+	for i := 0; i < transfers; i++ {
+		go func() {
+			defer wg.Done()
+			store.UpdatePair("acc-1", "acc-2", func(from, to *Account) error {
+				from.Balance -= 1
+				to.Balance += 1
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	from, _ := store.Get("acc-1")
+	to, _ := store.Get("acc-2")
+	if total := from.Balance + to.Balance; total != 100 {
+		t.Errorf("total balance drifted to %v, want 100 (debit/credit must be atomic)", total)
+	}
+	if from.Balance != float64(100-transfers) || to.Balance != float64(transfers) {
+		t.Errorf("balances = %v/%v, want %v/%v", from.Balance, to.Balance, 100-transfers, transfers)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIssueTokenThenParseJWTRoundTrips(t *testing.T) {
+	token, err := issueToken("acc-1", []string{"treasury"})
+	if err != nil {
+		t.Fatalf("issueToken: %v", err)
+	}
+	p, err := parseJWT(token)
+	if err != nil {
+		t.Fatalf("parseJWT: %v", err)
+	}
+	if p.Subject != "acc-1" {
+		t.Errorf("Subject = %q, want acc-1", p.Subject)
+	}
+	if !p.HasRole("treasury") {
+		t.Error("HasRole(treasury) = false, want true")
+	}
+}
+
+func TestParseJWTRejectsTamperedSignature(t *testing.T) {
+	token, err := issueToken("acc-1", nil)
+	if err != nil {
+		t.Fatalf("issueToken: %v", err)
+	}
+	tampered := token[:len(token)-1] + "x"
+	if _, err := parseJWT(tampered); err == nil {
+		t.Fatal("expected an error for a tampered signature")
+	}
+}
+
+func TestParseJWTRejectsMalformedToken(t *testing.T) {
+	if _, err := parseJWT("not-a-jwt"); err == nil {
+		t.Fatal("expected an error for a malformed token")
+	}
+}
+
+func TestCanActOnAccount(t *testing.T) {
+	cases := []struct {
+		name string
+		p    *Principal
+		acct string
+		want bool
+	}{
+		{"nil principal", nil, "acc-1", false},
+		{"account holder", &Principal{Subject: "acc-1"}, "acc-1", true},
+		{"different account, no treasury role", &Principal{Subject: "acc-2"}, "acc-1", false},
+		{"treasury role overrides account mismatch", &Principal{Subject: "acc-2", Roles: []string{"treasury"}}, "acc-1", true},
+	}
+	for _, c := range cases {
+		if got := canActOnAccount(c.p, c.acct); got != c.want {
+			t.Errorf("%s: canActOnAccount = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestRequireAuthRejectsMissingOrInvalidToken(t *testing.T) {
+	handler := requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("next must not run without a valid token")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/accounts/acc-1", nil)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status with no Authorization header = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/accounts/acc-1", nil)
+	req.Header.Set("Authorization", "Bearer not-a-jwt")
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status with a malformed token = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestRequireRoleRejectsMissingRoleWithForbidden(t *testing.T) {
+	handler := requireRole("treasury", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("next must not run without the required role")
+	})
+
+	token, err := issueToken("acc-1", []string{"customer"})
+	if err != nil {
+		t.Fatalf("issueToken: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/accounts/acc-1/freeze", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status without the treasury role = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestRequireRoleAllowsMatchingRole(t *testing.T) {
+	var ran bool
+	handler := requireRole("treasury", func(w http.ResponseWriter, r *http.Request) {
+		ran = true
+		w.WriteHeader(http.StatusOK)
+	})
+
+	token, err := issueToken("acc-1", []string{"treasury"})
+	if err != nil {
+		t.Fatalf("issueToken: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/accounts/acc-1/freeze", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if !ran {
+		t.Fatal("next did not run despite a matching role")
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrVersionMismatch is returned from an AccountStore.Update callback
+// when the caller's If-Match header doesn't match the account's current
+// version.
+var ErrVersionMismatch = errors.New("account version mismatch")
+
+// ErrAccountFrozen is returned from an AccountStore.Update callback when
+// the account is frozen and the requested mutation isn't allowed.
+var ErrAccountFrozen = errors.New("account is frozen")
+
+// AccountStore guards the account singleton behind a RWMutex, since
+// deposit/withdraw/freeze/unfreeze are all reachable concurrently from
+// the HTTP server. Unlike the multi-account API this file only ever
+// holds one account, so there's no map or List - just Get and Update.
+// The account carries a monotonically increasing version, bumped on
+// each successful Update, which handlers expose as an ETag and enforce
+// via If-Match for optimistic concurrency.
+type AccountStore struct {
+	mu      sync.RWMutex
+	account *Account
+}
+
+// NewAccountStore wraps seed, taking ownership of it.
+func NewAccountStore(seed *Account) *AccountStore {
+	return &AccountStore{account: seed}
+}
+
+// Get returns a copy of the current account.
+func (s *AccountStore) Get() Account {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return *s.account
+}
+
+// Update applies fn to the account under an exclusive lock and
+// increments its version once fn returns nil. This gives callers a
+// single critical section in which to compare the account's current
+// version against a client-supplied If-Match before mutating it. fn
+// must not retain the *Account it's given past its own return.
+func (s *AccountStore) Update(fn func(*Account) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := fn(s.account); err != nil {
+		return err
+	}
+	s.account.Version++
+	return nil
+}
+
+// etagFor renders an account version as the quoted ETag value handlers
+// emit on GET and compare If-Match against on writes.
+func etagFor(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Principal is the authenticated caller attached to a request's context
+// once the auth middleware verifies its bearer token.
+type Principal struct {
+	Subject string
+	Roles   []string
+}
+
+// HasRole reports whether p carries the given role.
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey int
+
+const principalContextKey contextKey = 0
+
+func withPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey, p)
+}
+
+func principalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalContextKey).(*Principal)
+	return p
+}
+
+// canActOnAccount reports whether p may deposit/withdraw on the account:
+// either p is the account holder, or p carries the treasury role.
+func canActOnAccount(p *Principal) bool {
+	if p == nil {
+		return false
+	}
+	return p.Subject == accountStore.Get().AccountID || p.HasRole("treasury")
+}
+
+// jwtSecret is the HS256 signing/verification key. Configure
+// TREASURY_JWT_SECRET in real deployments; the fallback is for local
+// testing only.
+var jwtSecret = []byte(envOr("TREASURY_JWT_SECRET", "dev-shared-secret"))
+
+// jwtRSAPublicKey, if TREASURY_JWT_RSA_PUBLIC_KEY (PEM-encoded) is set,
+// additionally allows RS256-signed tokens to be verified.
+var jwtRSAPublicKey = loadRSAPublicKey(os.Getenv("TREASURY_JWT_RSA_PUBLIC_KEY"))
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func loadRSAPublicKey(pemStr string) *rsa.PublicKey {
+	if pemStr == "" {
+		return nil
+	}
+	block, _ := pem.Decode([]byte(pemStr))
+	if block == nil {
+		return nil
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil
+	}
+	return rsaPub
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+type jwtClaims struct {
+	Subject string   `json:"sub"`
+	Roles   []string `json:"roles"`
+}
+
+// parseJWT verifies token's signature (HS256 against jwtSecret, or
+// RS256 against jwtRSAPublicKey when configured) and extracts its
+// principal from the sub/roles claims.
+func parseJWT(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("decoding header: %w", err)
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, fmt.Errorf("parsing header: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decoding signature: %w", err)
+	}
+	signedInput := parts[0] + "." + parts[1]
+
+	switch header.Alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, jwtSecret)
+		mac.Write([]byte(signedInput))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return nil, errors.New("invalid signature")
+		}
+	case "RS256":
+		if jwtRSAPublicKey == nil {
+			return nil, errors.New("RS256 not configured")
+		}
+		digest := sha256.Sum256([]byte(signedInput))
+		if err := rsa.VerifyPKCS1v15(jwtRSAPublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			return nil, errors.New("invalid signature")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("decoding claims: %w", err)
+	}
+	var claims jwtClaims
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return nil, fmt.Errorf("parsing claims: %w", err)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("missing sub claim")
+	}
+
+	return &Principal{Subject: claims.Subject, Roles: claims.Roles}, nil
+}
+
+// authenticate extracts and verifies the bearer token from r.
+func authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, errors.New("missing Authorization header")
+	}
+	const prefix = "Bearer "
+	if !strings.HasPrefix(header, prefix) {
+		return nil, errors.New("Authorization header must be a Bearer token")
+	}
+	return parseJWT(strings.TrimPrefix(header, prefix))
+}
+
+// requireAuth wraps next so it only runs once a valid bearer token has
+// been attached to the request context as a Principal. A missing or
+// invalid token is rejected with 401, before next ever sees the request.
+func requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := authenticate(r)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		next(w, r.WithContext(withPrincipal(r.Context(), principal)))
+	}
+}
+
+// requireRole wraps next in requireAuth and additionally rejects
+// principals missing role with 403.
+func requireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		principal := principalFromContext(r.Context())
+		if !principal.HasRole(role) {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("requires %s role", role)})
+			return
+		}
+		next(w, r)
+	})
+}
+
+// issueToken signs an HS256 JWT for subject/roles using jwtSecret. It
+// backs the /auth/token dev endpoint.
+func issueToken(subject string, roles []string) (string, error) {
+	headerJSON, err := json.Marshal(jwtHeader{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(jwtClaims{Subject: subject, Roles: roles})
+	if err != nil {
+		return "", err
+	}
+
+	signedInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	mac := hmac.New(sha256.New, jwtSecret)
+	mac.Write([]byte(signedInput))
+	signature := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	return signedInput + "." + signature, nil
+}
+
+// authToken is a dev-only endpoint that issues a signed token for the
+// subject/roles supplied in the request body, so the rest of the API
+// can be exercised locally without a real identity provider.
+func authToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Subject string   `json:"sub"`
+		Roles   []string `json:"roles"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if req.Subject == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "sub is required"})
+		return
+	}
+
+	token, err := issueToken(req.Subject, req.Roles)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"token": token})
+}
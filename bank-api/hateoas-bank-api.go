@@ -3,7 +3,12 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 type Link struct {
@@ -17,23 +22,343 @@ type Account struct {
 	AccountHolder string          `json:"accountHolder"`
 	Balance       float64         `json:"balance"`
 	Currency      string          `json:"currency"`
+	Frozen        bool            `json:"frozen"`
+	Version       int             `json:"-"`
 	Links         map[string]Link `json:"_links"`
 }
 
 type Transaction struct {
 	Amount float64 `json:"amount"`
+	Nonce  string  `json:"nonce,omitempty"`
 }
 
-var balance = 1250.75
-
-var account = &Account{
+// accountStore holds the account singleton behind a RWMutex and tracks
+// its version for optimistic concurrency; see account_store.go.
+var accountStore = NewAccountStore(&Account{
 	AccountID:     "acc-123",
 	AccountHolder: "John Doe",
-	Balance:       balance,
+	Balance:       1250.75,
 	Currency:      "USD",
+})
+
+// txStore is the ledger of record. The account's Balance is seeded from
+// it at startup and every deposit/withdraw appends to it rather than
+// mutating a standalone float.
+var txStore TransactionStore = newTxStore()
+
+func init() {
+	current := accountStore.Get()
+	// A persistent store (e.g. SQLite) may already have entries from a
+	// prior run; only seed the hardcoded starting balance once, and
+	// otherwise just replay what's there.
+	balance := current.Balance
+	if txStore.HasEntries() {
+		balance = txStore.Balance()
+	} else {
+		rec, _, err := txStore.Append("deposit", current.Balance, current.Currency, "")
+		if err != nil {
+			panic(fmt.Sprintf("seeding ledger: %v", err))
+		}
+		balance = rec.ResultingBalance
+	}
+	accountStore.Update(func(a *Account) error {
+		a.Balance = balance
+		return nil
+	})
+}
+
+// requestNonce returns the idempotency key for a request: the
+// Idempotency-Key header if present, otherwise the nonce carried in the
+// request body.
+func requestNonce(r *http.Request, bodyNonce string) string {
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		return key
+	}
+	return bodyNonce
+}
+
+// requireNonce writes a 400 and reports false if the request carries
+// neither an Idempotency-Key header nor a body nonce; every mutating
+// POST requires one so replays can be detected.
+func requireNonce(w http.ResponseWriter, nonce string) bool {
+	if nonce != "" {
+		return true
+	}
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"error": "Idempotency-Key header or nonce is required"})
+	return false
+}
+
+// replayIdempotentResponse writes back a previously recorded response
+// verbatim.
+func replayIdempotentResponse(w http.ResponseWriter, resp *idempotentResponse) {
+	if resp == nil {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	if resp.ContentType != "" {
+		w.Header().Set("Content-Type", resp.ContentType)
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(resp.Body)
+}
+
+// Representer is implemented by anything that can render itself in the
+// media types this API negotiates over: HAL+JSON, plain JSON, and HTML.
+type Representer interface {
+	MarshalHAL() ([]byte, error)
+	MarshalJSON() ([]byte, error)
+	MarshalHTML() ([]byte, error)
+}
+
+// plainAccount is the "application/json" representation: the resource
+// without HATEOAS affordances.
+type plainAccount struct {
+	AccountID     string  `json:"accountId"`
+	AccountHolder string  `json:"accountHolder"`
+	Balance       float64 `json:"balance"`
+	Currency      string  `json:"currency"`
+	Frozen        bool    `json:"frozen"`
+}
+
+func (a *Account) MarshalHAL() ([]byte, error) {
+	type halAccount Account // avoid recursing back into MarshalJSON
+	return json.Marshal((*halAccount)(a))
+}
+
+func (a *Account) MarshalJSON() ([]byte, error) {
+	return json.Marshal(plainAccount{
+		AccountID:     a.AccountID,
+		AccountHolder: a.AccountHolder,
+		Balance:       a.Balance,
+		Currency:      a.Currency,
+		Frozen:        a.Frozen,
+	})
+}
+
+func (a *Account) MarshalHTML() ([]byte, error) {
+	var buf strings.Builder
+	if err := accountHTMLTemplate.Execute(&buf, a); err != nil {
+		return nil, err
+	}
+	return []byte(buf.String()), nil
+}
+
+var accountHTMLTemplate = template.Must(template.New("account").Parse(`<!doctype html>
+<html>
+<head><title>Account {{.AccountID}}</title></head>
+<body>
+	<h1>{{.AccountHolder}}</h1>
+	<p>Account: {{.AccountID}}</p>
+	<p>Balance: {{.Balance}} {{.Currency}}</p>
+	<ul>
+	{{range $rel, $link := .Links}}
+		<li><a href="{{$link.Href}}" rel="{{$rel}}">{{$rel}}</a> ({{$link.Method}})</li>
+	{{end}}
+	</ul>
+</body>
+</html>
+`))
+
+// transactionCollection is the HAL representation of
+// GET /account/transactions.
+type transactionCollection struct {
+	Links    map[string]Link            `json:"_links"`
+	Embedded transactionCollectionEmbed `json:"_embedded"`
+}
+
+type transactionCollectionEmbed struct {
+	Transactions []TransactionRecord `json:"transactions"`
+}
+
+func (c *transactionCollection) MarshalHAL() ([]byte, error) {
+	type halCollection transactionCollection
+	return json.Marshal((*halCollection)(c))
+}
+
+func (c *transactionCollection) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Embedded.Transactions)
+}
+
+func (c *transactionCollection) MarshalHTML() ([]byte, error) {
+	var buf strings.Builder
+	if err := transactionsHTMLTemplate.Execute(&buf, c.Embedded.Transactions); err != nil {
+		return nil, err
+	}
+	return []byte(buf.String()), nil
+}
+
+var transactionsHTMLTemplate = template.Must(template.New("transactions").Parse(`<!doctype html>
+<html>
+<head><title>Transaction history</title></head>
+<body>
+	<h1>Transaction history</h1>
+	<ul>
+	{{range .}}
+		<li>{{.Timestamp}} - {{.Type}} {{.Amount}} {{.Currency}} (balance after: {{.ResultingBalance}})</li>
+	{{end}}
+	</ul>
+</body>
+</html>
+`))
+
+// Supported media types. When one Accept range matches several of them
+// (e.g. application/*), the first in supportedMediaTypes wins; ranges
+// tied on q and specificity keep the order they have in the header.
+const (
+	mediaTypeHAL  = "application/hal+json"
+	mediaTypeJSON = "application/json"
+	mediaTypeHTML = "text/html"
+)
+
+var supportedMediaTypes = []string{mediaTypeHAL, mediaTypeJSON, mediaTypeHTML}
+
+// mediaRange is one entry of a parsed Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
 }
 
-func addHATEOASLinks(account *Account, baseURL string) {
+func (m mediaRange) specificity() int {
+	if m.typ == "*" {
+		return 0
+	}
+	if m.subtype == "*" {
+		return 1
+	}
+	return 2
+}
+
+func (m mediaRange) matches(mediaType string) bool {
+	parts := strings.SplitN(mediaType, "/", 2)
+	typ, subtype := parts[0], parts[1]
+	if m.typ != "*" && m.typ != typ {
+		return false
+	}
+	if m.subtype != "*" && m.subtype != subtype {
+		return false
+	}
+	return true
+}
+
+// parseAccept parses an Accept header into media ranges ordered by
+// q-value (descending) then specificity (descending).
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		segments := strings.Split(part, ";")
+		typeAndSubtype := strings.TrimSpace(segments[0])
+		pieces := strings.SplitN(typeAndSubtype, "/", 2)
+		if len(pieces) != 2 {
+			continue
+		}
+		mr := mediaRange{typ: pieces[0], subtype: pieces[1], q: 1.0}
+		for _, param := range segments[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+				mr.q = q
+			}
+		}
+		ranges = append(ranges, mr)
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
+
+// negotiateMediaType picks the best server-supported media type for the
+// given Accept header, falling back to HAL when the header is missing
+// or "*/*". It returns an error if no supported type overlaps.
+func negotiateMediaType(accept string) (string, error) {
+	if accept == "" || accept == "*/*" {
+		return mediaTypeHAL, nil
+	}
+	for _, mr := range parseAccept(accept) {
+		if mr.q == 0 {
+			continue
+		}
+		for _, supported := range supportedMediaTypes {
+			if mr.matches(supported) {
+				return supported, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no acceptable media type for %q", accept)
+}
+
+// renderRepresenter negotiates content type against the request's Accept
+// header and writes the matching representation of rep.
+func renderRepresenter(w http.ResponseWriter, r *http.Request, rep Representer) {
+	mediaType, err := negotiateMediaType(r.Header.Get("Accept"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+
+	var body []byte
+	switch mediaType {
+	case mediaTypeHAL:
+		body, err = rep.MarshalHAL()
+	case mediaTypeHTML:
+		body, err = rep.MarshalHTML()
+	default:
+		body, err = rep.MarshalJSON()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.Write(body)
+}
+
+// renderAndRecord behaves like renderRepresenter, but also hands the
+// negotiated content type and body to record so a handler can store the
+// response against an idempotency nonce.
+func renderAndRecord(w http.ResponseWriter, r *http.Request, rep Representer, record func(contentType string, body []byte)) {
+	mediaType, err := negotiateMediaType(r.Header.Get("Accept"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+
+	var body []byte
+	switch mediaType {
+	case mediaTypeHAL:
+		body, err = rep.MarshalHAL()
+	case mediaTypeHTML:
+		body, err = rep.MarshalHTML()
+	default:
+		body, err = rep.MarshalJSON()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	record(mediaType, body)
+
+	w.Header().Set("Content-Type", mediaType)
+	w.Write(body)
+}
+
+// addHATEOASLinks populates account.Links. principal may be nil (an
+// unauthenticated caller); the freeze/unfreeze affordances only appear
+// for a principal holding the treasury role, so the links reflect what
+// the caller is authorized to do, not just the account's state.
+func addHATEOASLinks(account *Account, baseURL string, principal *Principal) {
 	links := make(map[string]Link)
 
 	// Self link - always present
@@ -50,6 +375,13 @@ func addHATEOASLinks(account *Account, baseURL string) {
 		Rel:    "deposit",
 	}
 
+	// History link - always available
+	links["history"] = Link{
+		Href:   fmt.Sprintf("%s/account/transactions", baseURL),
+		Method: "GET",
+		Rel:    "history",
+	}
+
 	// Withdraw link - only available if balance is positive
 	if account.Balance > 0 {
 		links["withdraw"] = Link{
@@ -59,6 +391,24 @@ func addHATEOASLinks(account *Account, baseURL string) {
 		}
 	}
 
+	// Freeze/unfreeze links - treasury role only, and only the one that
+	// applies to the account's current state.
+	if principal != nil && principal.HasRole("treasury") {
+		if account.Frozen {
+			links["unfreeze"] = Link{
+				Href:   fmt.Sprintf("%s/account/unfreeze", baseURL),
+				Method: "POST",
+				Rel:    "unfreeze",
+			}
+		} else {
+			links["freeze"] = Link{
+				Href:   fmt.Sprintf("%s/account/freeze", baseURL),
+				Method: "POST",
+				Rel:    "freeze",
+			}
+		}
+	}
+
 	account.Links = links
 }
 
@@ -68,11 +418,15 @@ func getAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	accountCopy := accountStore.Get()
 	baseURL := fmt.Sprintf("http://%s", r.Host)
-	addHATEOASLinks(account, baseURL)
+	// Auth is optional here: an unauthenticated GET still works, it just
+	// won't see freeze/unfreeze affordances.
+	principal, _ := authenticate(r)
+	addHATEOASLinks(&accountCopy, baseURL, principal)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(account)
+	w.Header().Set("ETag", etagFor(accountCopy.Version))
+	renderRepresenter(w, r, &accountCopy)
 }
 
 func deposit(w http.ResponseWriter, r *http.Request) {
@@ -81,6 +435,13 @@ func deposit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	principal := principalFromContext(r.Context())
+	if !canActOnAccount(principal) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "not authorized for this account"})
+		return
+	}
+
 	var transaction Transaction
 	if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -93,14 +454,60 @@ func deposit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	account.Balance += transaction.Amount
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		w.WriteHeader(http.StatusPreconditionRequired)
+		json.NewEncoder(w).Encode(map[string]string{"error": "If-Match header is required"})
+		return
+	}
+
+	nonce := requestNonce(r, transaction.Nonce)
+	if !requireNonce(w, nonce) {
+		return
+	}
+	var rec TransactionRecord
+	var replay *idempotentResponse
+	err := accountStore.Update(func(a *Account) error {
+		if etagFor(a.Version) != ifMatch {
+			return ErrVersionMismatch
+		}
+		if a.Frozen {
+			return ErrAccountFrozen
+		}
+		var appendErr error
+		rec, replay, appendErr = txStore.Append("deposit", transaction.Amount, a.Currency, nonce)
+		if appendErr != nil {
+			return appendErr
+		}
+		a.Balance = rec.ResultingBalance
+		return nil
+	})
+	switch {
+	case err == ErrVersionMismatch:
+		w.WriteHeader(http.StatusPreconditionFailed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "account has been modified since If-Match; refetch and retry"})
+		return
+	case err == ErrAccountFrozen:
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "account is frozen"})
+		return
+	case err == ErrNonceReplayed:
+		replayIdempotentResponse(w, replay)
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 
 	// Return updated account with HATEOAS links
+	accountCopy := accountStore.Get()
 	baseURL := fmt.Sprintf("http://%s", r.Host)
-	addHATEOASLinks(account, baseURL)
+	addHATEOASLinks(&accountCopy, baseURL, principal)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(account)
+	w.Header().Set("ETag", etagFor(accountCopy.Version))
+	renderAndRecord(w, r, &accountCopy, func(contentType string, body []byte) {
+		txStore.RecordResponse(nonce, idempotentResponse{StatusCode: http.StatusOK, ContentType: contentType, Body: body})
+	})
 }
 
 func withdraw(w http.ResponseWriter, r *http.Request) {
@@ -109,10 +516,10 @@ func withdraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if withdraw is allowed based on current balance
-	if balance <= 0 {
+	principal := principalFromContext(r.Context())
+	if !canActOnAccount(principal) {
 		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Withdrawal not allowed with negative balance"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "not authorized for this account"})
 		return
 	}
 
@@ -128,14 +535,150 @@ func withdraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	account.Balance -= transaction.Amount
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		w.WriteHeader(http.StatusPreconditionRequired)
+		json.NewEncoder(w).Encode(map[string]string{"error": "If-Match header is required"})
+		return
+	}
+
+	nonce := requestNonce(r, transaction.Nonce)
+	if !requireNonce(w, nonce) {
+		return
+	}
+	var rec TransactionRecord
+	var replay *idempotentResponse
+	err := accountStore.Update(func(a *Account) error {
+		if etagFor(a.Version) != ifMatch {
+			return ErrVersionMismatch
+		}
+		if a.Frozen {
+			return ErrAccountFrozen
+		}
+		if txStore.Balance() <= 0 {
+			return ErrInsufficientFunds
+		}
+		var appendErr error
+		rec, replay, appendErr = txStore.Append("withdraw", transaction.Amount, a.Currency, nonce)
+		if appendErr != nil {
+			return appendErr
+		}
+		a.Balance = rec.ResultingBalance
+		return nil
+	})
+	switch {
+	case err == ErrVersionMismatch:
+		w.WriteHeader(http.StatusPreconditionFailed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "account has been modified since If-Match; refetch and retry"})
+		return
+	case err == ErrAccountFrozen:
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "account is frozen"})
+		return
+	case err == ErrInsufficientFunds:
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Withdrawal not allowed with negative balance"})
+		return
+	case err == ErrNonceReplayed:
+		replayIdempotentResponse(w, replay)
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 
 	// Return updated account with HATEOAS links
+	accountCopy := accountStore.Get()
 	baseURL := fmt.Sprintf("http://%s", r.Host)
-	addHATEOASLinks(account, baseURL)
+	addHATEOASLinks(&accountCopy, baseURL, principal)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(account)
+	w.Header().Set("ETag", etagFor(accountCopy.Version))
+	renderAndRecord(w, r, &accountCopy, func(contentType string, body []byte) {
+		txStore.RecordResponse(nonce, idempotentResponse{StatusCode: http.StatusOK, ContentType: contentType, Body: body})
+	})
+}
+
+// setFrozen backs the treasury-only freeze/unfreeze endpoints.
+func setFrozen(w http.ResponseWriter, r *http.Request, frozen bool) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		w.WriteHeader(http.StatusPreconditionRequired)
+		json.NewEncoder(w).Encode(map[string]string{"error": "If-Match header is required"})
+		return
+	}
+
+	err := accountStore.Update(func(a *Account) error {
+		if etagFor(a.Version) != ifMatch {
+			return ErrVersionMismatch
+		}
+		a.Frozen = frozen
+		return nil
+	})
+	if err == ErrVersionMismatch {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "account has been modified since If-Match; refetch and retry"})
+		return
+	}
+
+	accountCopy := accountStore.Get()
+	baseURL := fmt.Sprintf("http://%s", r.Host)
+	addHATEOASLinks(&accountCopy, baseURL, principalFromContext(r.Context()))
+
+	w.Header().Set("ETag", etagFor(accountCopy.Version))
+	renderRepresenter(w, r, &accountCopy)
+}
+
+func freeze(w http.ResponseWriter, r *http.Request) {
+	setFrozen(w, r, true)
+}
+
+func unfreeze(w http.ResponseWriter, r *http.Request) {
+	setFrozen(w, r, false)
+}
+
+func transactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	cursor := query.Get("cursor")
+
+	records, next, prev, err := txStore.List(limit, cursor)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	baseURL := fmt.Sprintf("http://%s", r.Host)
+	selfHref := fmt.Sprintf("%s/account/transactions", baseURL)
+
+	links := map[string]Link{
+		"self":    {Href: selfHref, Method: "GET", Rel: "self"},
+		"account": {Href: fmt.Sprintf("%s/account", baseURL), Method: "GET", Rel: "account"},
+	}
+	if next != "" {
+		links["next"] = Link{Href: fmt.Sprintf("%s?cursor=%s", selfHref, url.QueryEscape(next)), Method: "GET", Rel: "next"}
+	}
+	if prev != "" {
+		links["prev"] = Link{Href: fmt.Sprintf("%s?cursor=%s", selfHref, url.QueryEscape(prev)), Method: "GET", Rel: "prev"}
+	} else if cursor != "" {
+		links["prev"] = Link{Href: selfHref, Method: "GET", Rel: "prev"}
+	}
+
+	collection := &transactionCollection{
+		Links:    links,
+		Embedded: transactionCollectionEmbed{Transactions: records},
+	}
+
+	renderRepresenter(w, r, collection)
 }
 
 func main() {
@@ -152,17 +695,33 @@ func main() {
 	})
 	http.HandleFunc("/account/deposit", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Printf("%s %s\n", r.Method, r.URL.Path)
-		deposit(w, r)
+		requireAuth(deposit)(w, r)
 	})
 	http.HandleFunc("/account/withdraw", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Printf("%s %s\n", r.Method, r.URL.Path)
-		withdraw(w, r)
+		requireAuth(withdraw)(w, r)
+	})
+	http.HandleFunc("/account/freeze", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("%s %s\n", r.Method, r.URL.Path)
+		requireRole("treasury", freeze)(w, r)
+	})
+	http.HandleFunc("/account/unfreeze", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("%s %s\n", r.Method, r.URL.Path)
+		requireRole("treasury", unfreeze)(w, r)
+	})
+	http.HandleFunc("/account/transactions", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("%s %s\n", r.Method, r.URL.Path)
+		transactions(w, r)
 	})
+	http.HandleFunc("/auth/token", authToken)
 
 	fmt.Println("HATEOAS Bank API server starting on :9001")
 	fmt.Println("Try: open http://localhost:9001/")
+	fmt.Println("Try: curl -X POST -d '{\"sub\": \"acc-123\", \"roles\": []}' http://localhost:9001/auth/token")
 	fmt.Println("Try: curl http://localhost:9001/account")
-	fmt.Println("Try: curl -X POST -H 'Content-Type: application/json' -d '{\"amount\": 100}' http://localhost:9001/account/deposit")
+	fmt.Println("Try: curl -H 'Accept: text/html' http://localhost:9001/account")
+	fmt.Println("Try: curl -X POST -H 'Authorization: Bearer <token>' -H 'If-Match: \"1\"' -H 'Idempotency-Key: <unique-key>' -H 'Content-Type: application/json' -d '{\"amount\": 100}' http://localhost:9001/account/deposit")
+	fmt.Println("Try: curl http://localhost:9001/account/transactions")
 
 	err := http.ListenAndServe(":9001", http.DefaultServeMux)
 	if err != nil {
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TransactionRecord is one immutable entry in the account's ledger.
+type TransactionRecord struct {
+	ID               string    `json:"id"`
+	Type             string    `json:"type"`
+	Amount           float64   `json:"amount"`
+	Currency         string    `json:"currency"`
+	Timestamp        time.Time `json:"timestamp"`
+	Nonce            string    `json:"nonce,omitempty"`
+	ResultingBalance float64   `json:"resultingBalance"`
+}
+
+// idempotentResponse is what gets replayed when a client resends a
+// nonce we've already recorded.
+type idempotentResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// nonceWindow is how long a nonce is remembered before it can be reused.
+const nonceWindow = 24 * time.Hour
+
+// ErrNonceReplayed is returned by TransactionStore.Append when the given
+// nonce was already recorded within the idempotency window.
+var ErrNonceReplayed = errors.New("nonce already used")
+
+// ErrInsufficientFunds is returned from withdraw's AccountStore.Update
+// callback when the account balance is already at or below zero.
+var ErrInsufficientFunds = errors.New("balance would go negative")
+
+// TransactionStore records every deposit/withdraw as an immutable
+// ledger entry for the account and enforces idempotency on
+// client-supplied nonces. This file only has one account, so unlike
+// the multi-account API there's no accountId dimension to the store.
+type TransactionStore interface {
+	// Append records a new ledger entry. If nonce is non-empty and was
+	// already recorded within the idempotency window, Append instead
+	// returns ErrNonceReplayed along with the response recorded for the
+	// original request (nil if the caller hadn't recorded one yet).
+	Append(txnType string, amount float64, currency, nonce string) (TransactionRecord, *idempotentResponse, error)
+
+	// RecordResponse associates a completed HTTP response with a nonce
+	// already appended, so a replay can return it verbatim.
+	RecordResponse(nonce string, resp idempotentResponse)
+
+	// List returns up to limit entries starting after cursor
+	// (exclusive), plus cursors for the next and previous pages (empty
+	// when there is none).
+	List(limit int, cursor string) (records []TransactionRecord, next string, prev string, err error)
+
+	// Balance returns the current balance as of the last recorded
+	// ledger entry, or zero if there are none.
+	Balance() float64
+
+	// HasEntries reports whether the ledger already has at least one
+	// entry, so init() can skip reseeding the hardcoded starting
+	// balance on top of a persistent store's existing history.
+	HasEntries() bool
+}
+
+const defaultTransactionPageSize = 20
+
+type nonceEntry struct {
+	expiresAt time.Time
+	response  *idempotentResponse
+}
+
+// memoryTransactionStore is the default in-memory TransactionStore.
+type memoryTransactionStore struct {
+	mu      sync.Mutex
+	seq     int
+	records []TransactionRecord
+	nonces  map[string]nonceEntry
+}
+
+func NewMemoryTransactionStore() *memoryTransactionStore {
+	return &memoryTransactionStore{nonces: make(map[string]nonceEntry)}
+}
+
+func (s *memoryTransactionStore) Append(txnType string, amount float64, currency, nonce string) (TransactionRecord, *idempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if nonce != "" {
+		if entry, ok := s.nonces[nonce]; ok && time.Now().Before(entry.expiresAt) {
+			return TransactionRecord{}, entry.response, ErrNonceReplayed
+		}
+	}
+
+	s.seq++
+	balance := s.balanceLocked()
+	switch txnType {
+	case "deposit":
+		balance += amount
+	case "withdraw":
+		balance -= amount
+	}
+
+	rec := TransactionRecord{
+		ID:               fmt.Sprintf("txn-%d", s.seq),
+		Type:             txnType,
+		Amount:           amount,
+		Currency:         currency,
+		Timestamp:        time.Now(),
+		Nonce:            nonce,
+		ResultingBalance: balance,
+	}
+	s.records = append(s.records, rec)
+
+	if nonce != "" {
+		s.nonces[nonce] = nonceEntry{expiresAt: time.Now().Add(nonceWindow)}
+	}
+
+	return rec, nil, nil
+}
+
+func (s *memoryTransactionStore) RecordResponse(nonce string, resp idempotentResponse) {
+	if nonce == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.nonces[nonce]
+	entry.response = &resp
+	s.nonces[nonce] = entry
+}
+
+func (s *memoryTransactionStore) List(limit int, cursor string) ([]TransactionRecord, string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := 0
+	if cursor != "" {
+		for i, rec := range s.records {
+			if rec.ID == cursor {
+				start = i + 1
+				break
+			}
+		}
+	}
+	if limit <= 0 {
+		limit = defaultTransactionPageSize
+	}
+	end := start + limit
+	if end > len(s.records) {
+		end = len(s.records)
+	}
+	page := append([]TransactionRecord(nil), s.records[start:end]...)
+
+	var next, prev string
+	if end < len(s.records) {
+		next = s.records[end-1].ID
+	}
+	if start > 0 {
+		prevStart := start - limit
+		if prevStart < 0 {
+			prevStart = 0
+		}
+		if prevStart > 0 {
+			prev = s.records[prevStart-1].ID
+		}
+	}
+	return page, next, prev, nil
+}
+
+func (s *memoryTransactionStore) Balance() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.balanceLocked()
+}
+
+func (s *memoryTransactionStore) balanceLocked() float64 {
+	if len(s.records) == 0 {
+		return 0
+	}
+	return s.records[len(s.records)-1].ResultingBalance
+}
+
+func (s *memoryTransactionStore) HasEntries() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records) > 0
+}
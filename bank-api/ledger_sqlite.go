@@ -0,0 +1,208 @@
+//go:build sqlite
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteTransactionStore is a SQLite-backed TransactionStore, selected
+// at build time with `go build -tags sqlite`.
+type sqliteTransactionStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteTransactionStore opens (creating if necessary) a SQLite
+// database at path and ensures the ledger schema exists.
+func NewSQLiteTransactionStore(path string) (*sqliteTransactionStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("opening ledger database: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS transactions (
+			id                TEXT PRIMARY KEY,
+			type              TEXT NOT NULL,
+			amount            REAL NOT NULL,
+			currency          TEXT NOT NULL,
+			timestamp         DATETIME NOT NULL,
+			nonce             TEXT,
+			resulting_balance REAL NOT NULL,
+			seq               INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS idempotency_keys (
+			nonce        TEXT PRIMARY KEY,
+			expires_at   DATETIME NOT NULL,
+			status_code  INTEGER,
+			content_type TEXT,
+			body         BLOB
+		);
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("creating ledger schema: %w", err)
+	}
+
+	return &sqliteTransactionStore{db: db}, nil
+}
+
+func (s *sqliteTransactionStore) Append(txnType string, amount float64, currency, nonce string) (TransactionRecord, *idempotentResponse, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return TransactionRecord{}, nil, err
+	}
+	defer tx.Rollback()
+
+	if nonce != "" {
+		var expiresAt time.Time
+		var statusCode sql.NullInt64
+		var contentType sql.NullString
+		var body []byte
+		err := tx.QueryRow(
+			`SELECT expires_at, status_code, content_type, body FROM idempotency_keys WHERE nonce = ?`, nonce,
+		).Scan(&expiresAt, &statusCode, &contentType, &body)
+		if err == nil && time.Now().Before(expiresAt) {
+			var replay *idempotentResponse
+			if statusCode.Valid {
+				replay = &idempotentResponse{StatusCode: int(statusCode.Int64), ContentType: contentType.String, Body: body}
+			}
+			return TransactionRecord{}, replay, ErrNonceReplayed
+		}
+	}
+
+	var balance sql.NullFloat64
+	err = tx.QueryRow(`SELECT resulting_balance FROM transactions ORDER BY seq DESC LIMIT 1`).Scan(&balance)
+	if err != nil && err != sql.ErrNoRows {
+		return TransactionRecord{}, nil, err
+	}
+	newBalance := balance.Float64
+	switch txnType {
+	case "deposit":
+		newBalance += amount
+	case "withdraw":
+		newBalance -= amount
+	}
+
+	var seq int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM transactions`).Scan(&seq); err != nil {
+		return TransactionRecord{}, nil, err
+	}
+	seq++
+
+	rec := TransactionRecord{
+		ID:               fmt.Sprintf("txn-%d", seq),
+		Type:             txnType,
+		Amount:           amount,
+		Currency:         currency,
+		Timestamp:        time.Now(),
+		Nonce:            nonce,
+		ResultingBalance: newBalance,
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO transactions (id, type, amount, currency, timestamp, nonce, resulting_balance, seq)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.ID, rec.Type, rec.Amount, rec.Currency, rec.Timestamp, rec.Nonce, rec.ResultingBalance, seq,
+	)
+	if err != nil {
+		return TransactionRecord{}, nil, err
+	}
+
+	if nonce != "" {
+		_, err = tx.Exec(`INSERT INTO idempotency_keys (nonce, expires_at) VALUES (?, ?)`, nonce, time.Now().Add(nonceWindow))
+		if err != nil {
+			return TransactionRecord{}, nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return TransactionRecord{}, nil, err
+	}
+	return rec, nil, nil
+}
+
+func (s *sqliteTransactionStore) RecordResponse(nonce string, resp idempotentResponse) {
+	if nonce == "" {
+		return
+	}
+	s.db.Exec(
+		`UPDATE idempotency_keys SET status_code = ?, content_type = ?, body = ? WHERE nonce = ?`,
+		resp.StatusCode, resp.ContentType, resp.Body, nonce,
+	)
+}
+
+func (s *sqliteTransactionStore) List(limit int, cursor string) ([]TransactionRecord, string, string, error) {
+	if limit <= 0 {
+		limit = defaultTransactionPageSize
+	}
+
+	startSeq := 0
+	if cursor != "" {
+		if err := s.db.QueryRow(`SELECT seq FROM transactions WHERE id = ?`, cursor).Scan(&startSeq); err != nil {
+			return nil, "", "", err
+		}
+	}
+
+	rows, err := s.db.Query(
+		`SELECT id, type, amount, currency, timestamp, nonce, resulting_balance, seq
+		 FROM transactions WHERE seq > ? ORDER BY seq ASC LIMIT ?`,
+		startSeq, limit+1,
+	)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer rows.Close()
+
+	var records []TransactionRecord
+	for rows.Next() {
+		var rec TransactionRecord
+		var seq int
+		var nonce sql.NullString
+		if err := rows.Scan(&rec.ID, &rec.Type, &rec.Amount, &rec.Currency, &rec.Timestamp, &nonce, &rec.ResultingBalance, &seq); err != nil {
+			return nil, "", "", err
+		}
+		rec.Nonce = nonce.String
+		records = append(records, rec)
+	}
+
+	var next string
+	if len(records) > limit {
+		next = records[limit-1].ID
+		records = records[:limit]
+	}
+
+	var prev string
+	if startSeq > 0 {
+		prevStartSeq := startSeq - limit
+		if prevStartSeq < 0 {
+			prevStartSeq = 0
+		}
+		if prevStartSeq > 0 {
+			var id string
+			if err := s.db.QueryRow(`SELECT id FROM transactions WHERE seq = ?`, prevStartSeq).Scan(&id); err == nil {
+				prev = id
+			}
+		}
+	}
+
+	return records, next, prev, nil
+}
+
+func (s *sqliteTransactionStore) Balance() float64 {
+	var balance sql.NullFloat64
+	err := s.db.QueryRow(`SELECT resulting_balance FROM transactions ORDER BY seq DESC LIMIT 1`).Scan(&balance)
+	if err != nil {
+		return 0
+	}
+	return balance.Float64
+}
+
+func (s *sqliteTransactionStore) HasEntries() bool {
+	var exists int
+	err := s.db.QueryRow(`SELECT 1 FROM transactions LIMIT 1`).Scan(&exists)
+	return err == nil
+}
@@ -0,0 +1,20 @@
+//go:build sqlite
+
+package main
+
+import "fmt"
+
+// ledgerDBPath is the SQLite file the ledger is persisted to. Configure
+// LEDGER_DB_PATH in real deployments; the fallback is for local testing
+// only.
+var ledgerDBPath = envOr("LEDGER_DB_PATH", "ledger.db")
+
+// newTxStore builds the SQLite-backed TransactionStore selected by the
+// sqlite build tag (`go build -tags sqlite`).
+func newTxStore() TransactionStore {
+	store, err := NewSQLiteTransactionStore(ledgerDBPath)
+	if err != nil {
+		panic(fmt.Sprintf("opening ledger at %s: %v", ledgerDBPath, err))
+	}
+	return store
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNegotiateMediaType(t *testing.T) {
+	cases := []struct {
+		accept string
+		want   string
+	}{
+		{"", mediaTypeHAL},
+		{"*/*", mediaTypeHAL},
+		{"application/json", mediaTypeJSON},
+		{"text/html", mediaTypeHTML},
+		{"application/json;q=0.5, text/html;q=0.9", mediaTypeHTML},
+		{"application/json;q=0.9, application/hal+json;q=0.9", mediaTypeJSON},
+	}
+	for _, c := range cases {
+		got, err := negotiateMediaType(c.accept)
+		if err != nil {
+			t.Errorf("negotiateMediaType(%q): unexpected error: %v", c.accept, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("negotiateMediaType(%q) = %q, want %q", c.accept, got, c.want)
+		}
+	}
+}
+
+func TestNegotiateMediaTypeNoOverlap(t *testing.T) {
+	if _, err := negotiateMediaType("application/xml"); err == nil {
+		t.Fatal("expected an error when no supported type overlaps the Accept header")
+	}
+}
+
+func TestParseAcceptOrdersByQThenSpecificity(t *testing.T) {
+	ranges := parseAccept("text/*;q=0.8, application/json;q=0.8, */*;q=0.1")
+	if len(ranges) != 3 {
+		t.Fatalf("parseAccept: got %d ranges, want 3", len(ranges))
+	}
+	// Both text/* and application/json carry q=0.8; the fully-specific
+	// application/json must sort ahead of the wildcard text/*.
+	if ranges[0].typ != "application" || ranges[0].subtype != "json" {
+		t.Errorf("ranges[0] = %+v, want application/json first (same q, higher specificity)", ranges[0])
+	}
+	if ranges[2].typ != "*" {
+		t.Errorf("ranges[2] = %+v, want the q=0.1 */* last", ranges[2])
+	}
+}
+
+// TestAccountCollectionMarshalHALKeepsPerAccountLinks is a regression
+// test: encoding/json calls Account.MarshalJSON on every *Account it
+// finds, even nested inside another type's custom MarshalHAL, unless
+// the embedded accounts are first converted to halAccount.
+func TestAccountCollectionMarshalHALKeepsPerAccountLinks(t *testing.T) {
+	a := &Account{
+		AccountID: "acc-1",
+		Links:     map[string]Link{"self": {Href: "/accounts/acc-1", Method: "GET", Rel: "self"}},
+	}
+	collection := &accountCollection{
+		Links:    map[string]Link{"self": {Href: "/accounts", Method: "GET", Rel: "self"}},
+		Embedded: accountCollectionEmbed{Accounts: []*Account{a}},
+	}
+
+	body, err := collection.MarshalHAL()
+	if err != nil {
+		t.Fatalf("MarshalHAL: %v", err)
+	}
+
+	var decoded struct {
+		Embedded struct {
+			Accounts []struct {
+				Links map[string]Link `json:"_links"`
+			} `json:"accounts"`
+		} `json:"_embedded"`
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("decoding HAL body: %v", err)
+	}
+	if len(decoded.Embedded.Accounts) != 1 {
+		t.Fatalf("got %d embedded accounts, want 1", len(decoded.Embedded.Accounts))
+	}
+	if _, ok := decoded.Embedded.Accounts[0].Links["self"]; !ok {
+		t.Error("embedded account lost its _links during HAL marshaling")
+	}
+}
+
+// TestTransferResultMarshalHALKeepsPerAccountLinks covers the same
+// recursion bug for the transfer response's embedded source/destination.
+func TestTransferResultMarshalHALKeepsPerAccountLinks(t *testing.T) {
+	source := &Account{AccountID: "acc-1", Links: map[string]Link{"self": {Href: "/accounts/acc-1"}}}
+	dest := &Account{AccountID: "acc-2", Links: map[string]Link{"self": {Href: "/accounts/acc-2"}}}
+	result := &transferResult{
+		Embedded: transferResultEmbed{Source: source, Destination: dest},
+	}
+
+	body, err := result.MarshalHAL()
+	if err != nil {
+		t.Fatalf("MarshalHAL: %v", err)
+	}
+
+	type accountLinks struct {
+		Links map[string]Link `json:"_links"`
+	}
+	var decoded struct {
+		Embedded struct {
+			Source      accountLinks `json:"source"`
+			Destination accountLinks `json:"destination"`
+		} `json:"_embedded"`
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("decoding HAL body: %v", err)
+	}
+	if _, ok := decoded.Embedded.Source.Links["self"]; !ok {
+		t.Error("embedded source account lost its _links during HAL marshaling")
+	}
+	if _, ok := decoded.Embedded.Destination.Links["self"]; !ok {
+		t.Error("embedded destination account lost its _links during HAL marshaling")
+	}
+}
@@ -2,8 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -18,14 +23,31 @@ type Account struct {
 	AccountHolder string          `json:"accountHolder"`
 	Balance       float64         `json:"balance"`
 	Currency      string          `json:"currency"`
+	Frozen        bool            `json:"frozen"`
+	Version       int             `json:"-"`
 	Links         map[string]Link `json:"_links"`
 }
 
 type Transaction struct {
 	Amount float64 `json:"amount"`
+	Nonce  string  `json:"nonce,omitempty"`
 }
 
-var accounts = map[string]*Account{
+// Transfer moves funds from the account in the URL to ToAccountID.
+type Transfer struct {
+	ToAccountID string  `json:"toAccountId"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	Nonce       string  `json:"nonce,omitempty"`
+}
+
+// ErrCurrencyMismatch is returned from a transfer's AccountStore.UpdatePair
+// callback when the transfer's currency doesn't match both accounts'.
+var ErrCurrencyMismatch = errors.New("currency mismatch between accounts")
+
+// accountStore holds every account behind a RWMutex and tracks each
+// one's version for optimistic concurrency; see account_store.go.
+var accountStore = NewAccountStore(map[string]*Account{
 	"acc-123": {
 		AccountID:     "acc-123",
 		AccountHolder: "John Doe",
@@ -38,9 +60,485 @@ var accounts = map[string]*Account{
 		Balance:       -150.25,
 		Currency:      "USD",
 	},
+})
+
+// txStore is the ledger of record. Each account's Balance is seeded
+// from it at startup and every deposit/withdraw/transfer appends to it
+// rather than mutating a standalone float.
+var txStore TransactionStore = newTxStore()
+
+func init() {
+	for _, acct := range accountStore.List() {
+		// A persistent store (e.g. SQLite) may already have entries for
+		// this account from a prior run; only seed the hardcoded
+		// starting balance once, and otherwise just replay what's there.
+		balance := acct.Balance
+		if txStore.HasEntries(acct.AccountID) {
+			balance = txStore.Balance(acct.AccountID)
+		} else {
+			rec, _, err := txStore.Append(acct.AccountID, "deposit", acct.Balance, acct.Currency, "")
+			if err != nil {
+				panic(fmt.Sprintf("seeding ledger for %s: %v", acct.AccountID, err))
+			}
+			balance = rec.ResultingBalance
+		}
+		err := accountStore.Update(acct.AccountID, func(a *Account) error {
+			a.Balance = balance
+			return nil
+		})
+		if err != nil {
+			panic(fmt.Sprintf("seeding ledger for %s: %v", acct.AccountID, err))
+		}
+	}
+}
+
+// requestNonce returns the idempotency key for a request: the
+// Idempotency-Key header if present, otherwise the nonce carried in the
+// request body.
+func requestNonce(r *http.Request, bodyNonce string) string {
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		return key
+	}
+	return bodyNonce
+}
+
+// requireNonce writes a 400 and reports false if the request carries
+// neither an Idempotency-Key header nor a body nonce; every mutating
+// POST requires one so replays can be detected.
+func requireNonce(w http.ResponseWriter, nonce string) bool {
+	if nonce != "" {
+		return true
+	}
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"error": "Idempotency-Key header or nonce is required"})
+	return false
+}
+
+// replayIdempotentResponse writes back a previously recorded response
+// verbatim.
+func replayIdempotentResponse(w http.ResponseWriter, resp *idempotentResponse) {
+	if resp == nil {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	if resp.ContentType != "" {
+		w.Header().Set("Content-Type", resp.ContentType)
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(resp.Body)
+}
+
+// Representer is implemented by anything that can render itself in the
+// media types this API negotiates over: HAL+JSON, plain JSON, and HTML.
+type Representer interface {
+	MarshalHAL() ([]byte, error)
+	MarshalJSON() ([]byte, error)
+	MarshalHTML() ([]byte, error)
+}
+
+// plainAccount is the "application/json" representation: the resource
+// without HATEOAS affordances.
+type plainAccount struct {
+	AccountID     string  `json:"accountId"`
+	AccountHolder string  `json:"accountHolder"`
+	Balance       float64 `json:"balance"`
+	Currency      string  `json:"currency"`
+	Frozen        bool    `json:"frozen"`
+}
+
+// halAccount is Account stripped of its MarshalJSON method, so encoding
+// it falls back to plain struct marshaling (every field, including
+// Links) instead of recursing back into Account.MarshalJSON. Anything
+// that embeds one or more Accounts in a HAL document - not just Account
+// itself - needs to convert through this type, since encoding/json
+// calls MarshalJSON on every *Account it finds, however deeply nested.
+type halAccount Account
+
+func (a *Account) MarshalHAL() ([]byte, error) {
+	return json.Marshal((*halAccount)(a))
+}
+
+// toHalAccounts converts a slice of *Account for HAL marshaling; see
+// halAccount.
+func toHalAccounts(accounts []*Account) []*halAccount {
+	out := make([]*halAccount, len(accounts))
+	for i, a := range accounts {
+		out[i] = (*halAccount)(a)
+	}
+	return out
+}
+
+func (a *Account) MarshalJSON() ([]byte, error) {
+	return json.Marshal(plainAccount{
+		AccountID:     a.AccountID,
+		AccountHolder: a.AccountHolder,
+		Balance:       a.Balance,
+		Currency:      a.Currency,
+		Frozen:        a.Frozen,
+	})
+}
+
+func (a *Account) MarshalHTML() ([]byte, error) {
+	var buf strings.Builder
+	if err := accountHTMLTemplate.Execute(&buf, a); err != nil {
+		return nil, err
+	}
+	return []byte(buf.String()), nil
+}
+
+// accountCollection is the HAL representation of GET /accounts.
+type accountCollection struct {
+	Links    map[string]Link        `json:"_links"`
+	Embedded accountCollectionEmbed `json:"_embedded"`
+}
+
+type accountCollectionEmbed struct {
+	Accounts []*Account `json:"accounts"`
+}
+
+// halAccountCollectionEmbed mirrors accountCollectionEmbed with its
+// Accounts converted to halAccount so each one keeps its _links; see
+// halAccount.
+type halAccountCollectionEmbed struct {
+	Accounts []*halAccount `json:"accounts"`
 }
 
-func addHATEOASLinks(account *Account, baseURL string) {
+func (c *accountCollection) MarshalHAL() ([]byte, error) {
+	return json.Marshal(struct {
+		Links    map[string]Link           `json:"_links"`
+		Embedded halAccountCollectionEmbed `json:"_embedded"`
+	}{
+		Links:    c.Links,
+		Embedded: halAccountCollectionEmbed{Accounts: toHalAccounts(c.Embedded.Accounts)},
+	})
+}
+
+func (c *accountCollection) MarshalJSON() ([]byte, error) {
+	plain := make([]plainAccount, 0, len(c.Embedded.Accounts))
+	for _, a := range c.Embedded.Accounts {
+		plain = append(plain, plainAccount{
+			AccountID:     a.AccountID,
+			AccountHolder: a.AccountHolder,
+			Balance:       a.Balance,
+			Currency:      a.Currency,
+			Frozen:        a.Frozen,
+		})
+	}
+	return json.Marshal(plain)
+}
+
+func (c *accountCollection) MarshalHTML() ([]byte, error) {
+	var buf strings.Builder
+	if err := collectionHTMLTemplate.Execute(&buf, c.Embedded.Accounts); err != nil {
+		return nil, err
+	}
+	return []byte(buf.String()), nil
+}
+
+// transferResult is the HAL representation of a completed transfer,
+// embedding both sides so a client can see the effect without a
+// follow-up GET.
+type transferResult struct {
+	Links    map[string]Link     `json:"_links"`
+	Embedded transferResultEmbed `json:"_embedded"`
+}
+
+type transferResultEmbed struct {
+	Source      *Account `json:"source"`
+	Destination *Account `json:"destination"`
+}
+
+// halTransferResultEmbed mirrors transferResultEmbed with its accounts
+// converted to halAccount so each one keeps its _links; see halAccount.
+type halTransferResultEmbed struct {
+	Source      *halAccount `json:"source"`
+	Destination *halAccount `json:"destination"`
+}
+
+func (t *transferResult) MarshalHAL() ([]byte, error) {
+	return json.Marshal(struct {
+		Links    map[string]Link        `json:"_links"`
+		Embedded halTransferResultEmbed `json:"_embedded"`
+	}{
+		Links: t.Links,
+		Embedded: halTransferResultEmbed{
+			Source:      (*halAccount)(t.Embedded.Source),
+			Destination: (*halAccount)(t.Embedded.Destination),
+		},
+	})
+}
+
+func (t *transferResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Source      plainAccount `json:"source"`
+		Destination plainAccount `json:"destination"`
+	}{
+		Source: plainAccount{
+			AccountID:     t.Embedded.Source.AccountID,
+			AccountHolder: t.Embedded.Source.AccountHolder,
+			Balance:       t.Embedded.Source.Balance,
+			Currency:      t.Embedded.Source.Currency,
+			Frozen:        t.Embedded.Source.Frozen,
+		},
+		Destination: plainAccount{
+			AccountID:     t.Embedded.Destination.AccountID,
+			AccountHolder: t.Embedded.Destination.AccountHolder,
+			Balance:       t.Embedded.Destination.Balance,
+			Currency:      t.Embedded.Destination.Currency,
+			Frozen:        t.Embedded.Destination.Frozen,
+		},
+	})
+}
+
+func (t *transferResult) MarshalHTML() ([]byte, error) {
+	var buf strings.Builder
+	if err := transferHTMLTemplate.Execute(&buf, t.Embedded); err != nil {
+		return nil, err
+	}
+	return []byte(buf.String()), nil
+}
+
+// transactionCollection is the HAL representation of
+// GET /accounts/{id}/transactions.
+type transactionCollection struct {
+	Links    map[string]Link            `json:"_links"`
+	Embedded transactionCollectionEmbed `json:"_embedded"`
+}
+
+type transactionCollectionEmbed struct {
+	Transactions []TransactionRecord `json:"transactions"`
+}
+
+func (c *transactionCollection) MarshalHAL() ([]byte, error) {
+	type halCollection transactionCollection
+	return json.Marshal((*halCollection)(c))
+}
+
+func (c *transactionCollection) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Embedded.Transactions)
+}
+
+func (c *transactionCollection) MarshalHTML() ([]byte, error) {
+	var buf strings.Builder
+	if err := transactionsHTMLTemplate.Execute(&buf, c.Embedded.Transactions); err != nil {
+		return nil, err
+	}
+	return []byte(buf.String()), nil
+}
+
+var transactionsHTMLTemplate = template.Must(template.New("transactions").Parse(`<!doctype html>
+<html>
+<head><title>Transaction history</title></head>
+<body>
+	<h1>Transaction history</h1>
+	<ul>
+	{{range .}}
+		<li>{{.Timestamp}} - {{.Type}} {{.Amount}} {{.Currency}} (balance after: {{.ResultingBalance}})</li>
+	{{end}}
+	</ul>
+</body>
+</html>
+`))
+
+var transferHTMLTemplate = template.Must(template.New("transfer").Parse(`<!doctype html>
+<html>
+<head><title>Transfer complete</title></head>
+<body>
+	<h1>Transfer complete</h1>
+	<p>Source: <a href="/accounts/{{.Source.AccountID}}">{{.Source.AccountID}}</a> - balance {{.Source.Balance}} {{.Source.Currency}}</p>
+	<p>Destination: <a href="/accounts/{{.Destination.AccountID}}">{{.Destination.AccountID}}</a> - balance {{.Destination.Balance}} {{.Destination.Currency}}</p>
+</body>
+</html>
+`))
+
+var accountHTMLTemplate = template.Must(template.New("account").Parse(`<!doctype html>
+<html>
+<head><title>Account {{.AccountID}}</title></head>
+<body>
+	<h1>{{.AccountHolder}}</h1>
+	<p>Account: {{.AccountID}}</p>
+	<p>Balance: {{.Balance}} {{.Currency}}</p>
+	<ul>
+	{{range $rel, $link := .Links}}
+		<li><a href="{{$link.Href}}" rel="{{$rel}}">{{$rel}}</a> ({{$link.Method}})</li>
+	{{end}}
+	</ul>
+	<p><a href="/accounts">All accounts</a></p>
+</body>
+</html>
+`))
+
+var collectionHTMLTemplate = template.Must(template.New("collection").Parse(`<!doctype html>
+<html>
+<head><title>Accounts</title></head>
+<body>
+	<h1>Accounts</h1>
+	<ul>
+	{{range .}}
+		<li><a href="/accounts/{{.AccountID}}">{{.AccountID}}</a> - {{.AccountHolder}} ({{.Balance}} {{.Currency}})</li>
+	{{end}}
+	</ul>
+</body>
+</html>
+`))
+
+// Supported media types. When one Accept range matches several of them
+// (e.g. application/*), the first in supportedMediaTypes wins; ranges
+// tied on q and specificity keep the order they have in the header.
+const (
+	mediaTypeHAL  = "application/hal+json"
+	mediaTypeJSON = "application/json"
+	mediaTypeHTML = "text/html"
+)
+
+var supportedMediaTypes = []string{mediaTypeHAL, mediaTypeJSON, mediaTypeHTML}
+
+// mediaRange is one entry of a parsed Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func (m mediaRange) specificity() int {
+	if m.typ == "*" {
+		return 0
+	}
+	if m.subtype == "*" {
+		return 1
+	}
+	return 2
+}
+
+func (m mediaRange) matches(mediaType string) bool {
+	parts := strings.SplitN(mediaType, "/", 2)
+	typ, subtype := parts[0], parts[1]
+	if m.typ != "*" && m.typ != typ {
+		return false
+	}
+	if m.subtype != "*" && m.subtype != subtype {
+		return false
+	}
+	return true
+}
+
+// parseAccept parses an Accept header into media ranges ordered by
+// q-value (descending) then specificity (descending).
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		segments := strings.Split(part, ";")
+		typeAndSubtype := strings.TrimSpace(segments[0])
+		pieces := strings.SplitN(typeAndSubtype, "/", 2)
+		if len(pieces) != 2 {
+			continue
+		}
+		mr := mediaRange{typ: pieces[0], subtype: pieces[1], q: 1.0}
+		for _, param := range segments[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+				mr.q = q
+			}
+		}
+		ranges = append(ranges, mr)
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
+
+// negotiateMediaType picks the best server-supported media type for the
+// given Accept header, falling back to HAL when the header is missing
+// or "*/*". It returns an error if no supported type overlaps.
+func negotiateMediaType(accept string) (string, error) {
+	if accept == "" || accept == "*/*" {
+		return mediaTypeHAL, nil
+	}
+	for _, mr := range parseAccept(accept) {
+		if mr.q == 0 {
+			continue
+		}
+		for _, supported := range supportedMediaTypes {
+			if mr.matches(supported) {
+				return supported, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no acceptable media type for %q", accept)
+}
+
+// renderRepresenter negotiates content type against the request's Accept
+// header and writes the matching representation of rep.
+func renderRepresenter(w http.ResponseWriter, r *http.Request, rep Representer) {
+	mediaType, err := negotiateMediaType(r.Header.Get("Accept"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+
+	var body []byte
+	switch mediaType {
+	case mediaTypeHAL:
+		body, err = rep.MarshalHAL()
+	case mediaTypeHTML:
+		body, err = rep.MarshalHTML()
+	default:
+		body, err = rep.MarshalJSON()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.Write(body)
+}
+
+// renderAndRecord behaves like renderRepresenter, but also hands the
+// negotiated content type and body to record so a handler can store the
+// response against an idempotency nonce.
+func renderAndRecord(w http.ResponseWriter, r *http.Request, rep Representer, record func(contentType string, body []byte)) {
+	mediaType, err := negotiateMediaType(r.Header.Get("Accept"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+
+	var body []byte
+	switch mediaType {
+	case mediaTypeHAL:
+		body, err = rep.MarshalHAL()
+	case mediaTypeHTML:
+		body, err = rep.MarshalHTML()
+	default:
+		body, err = rep.MarshalJSON()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	record(mediaType, body)
+
+	w.Header().Set("Content-Type", mediaType)
+	w.Write(body)
+}
+
+// addHATEOASLinks populates account.Links. principal may be nil (an
+// unauthenticated caller); the freeze/unfreeze affordances only appear
+// for a principal holding the treasury role, so the links reflect what
+// the caller is authorized to do, not just the account's state.
+func addHATEOASLinks(account *Account, baseURL string, principal *Principal) {
 	links := make(map[string]Link)
 
 	// Self link - always present
@@ -57,6 +555,13 @@ func addHATEOASLinks(account *Account, baseURL string) {
 		Rel:    "deposit",
 	}
 
+	// History link - always available
+	links["history"] = Link{
+		Href:   fmt.Sprintf("%s/accounts/%s/transactions", baseURL, account.AccountID),
+		Method: "GET",
+		Rel:    "history",
+	}
+
 	// Withdraw link - only available if balance is positive
 	if account.Balance > 0 {
 		links["withdraw"] = Link{
@@ -64,11 +569,66 @@ func addHATEOASLinks(account *Account, baseURL string) {
 			Method: "POST",
 			Rel:    "withdraw",
 		}
+
+		// Transfer link - only available if balance permits, same as withdraw
+		links["transfer"] = Link{
+			Href:   fmt.Sprintf("%s/accounts/%s/transfer", baseURL, account.AccountID),
+			Method: "POST",
+			Rel:    "transfer",
+		}
+	}
+
+	// Freeze/unfreeze links - treasury role only, and only the one that
+	// applies to the account's current state.
+	if principal != nil && principal.HasRole("treasury") {
+		if account.Frozen {
+			links["unfreeze"] = Link{
+				Href:   fmt.Sprintf("%s/accounts/%s/unfreeze", baseURL, account.AccountID),
+				Method: "POST",
+				Rel:    "unfreeze",
+			}
+		} else {
+			links["freeze"] = Link{
+				Href:   fmt.Sprintf("%s/accounts/%s/freeze", baseURL, account.AccountID),
+				Method: "POST",
+				Rel:    "freeze",
+			}
+		}
 	}
 
 	account.Links = links
 }
 
+func getAccounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	principal := principalFromContext(r.Context())
+	baseURL := fmt.Sprintf("http://%s", r.Host)
+
+	all := accountStore.List()
+	embedded := make([]*Account, 0, len(all))
+	for _, account := range all {
+		accountCopy := account
+		accountCopy.Balance = txStore.Balance(accountCopy.AccountID)
+		addHATEOASLinks(&accountCopy, baseURL, principal)
+		embedded = append(embedded, &accountCopy)
+	}
+	sort.Slice(embedded, func(i, j int) bool { return embedded[i].AccountID < embedded[j].AccountID })
+
+	collection := &accountCollection{
+		Links: map[string]Link{
+			"self": {Href: fmt.Sprintf("%s/accounts", baseURL), Method: "GET", Rel: "self"},
+			"find": {Href: fmt.Sprintf("%s/accounts/{id}", baseURL), Rel: "find"},
+		},
+		Embedded: accountCollectionEmbed{Accounts: embedded},
+	}
+
+	renderRepresenter(w, r, collection)
+}
+
 func getAccount(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -77,19 +637,21 @@ func getAccount(w http.ResponseWriter, r *http.Request) {
 
 	accountID := strings.TrimPrefix(r.URL.Path, "/accounts/")
 
-	account, exists := accounts[accountID]
-	if !exists {
+	accountCopy, err := accountStore.Get(accountID)
+	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	// Create a copy to avoid modifying the original
-	accountCopy := *account
+	accountCopy.Balance = txStore.Balance(accountCopy.AccountID)
 	baseURL := fmt.Sprintf("http://%s", r.Host)
-	addHATEOASLinks(&accountCopy, baseURL)
+	// Auth is optional here: an unauthenticated GET still works, it just
+	// won't see freeze/unfreeze affordances.
+	principal, _ := authenticate(r)
+	addHATEOASLinks(&accountCopy, baseURL, principal)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(accountCopy)
+	w.Header().Set("ETag", etagFor(accountCopy.Version))
+	renderRepresenter(w, r, &accountCopy)
 }
 
 func deposit(w http.ResponseWriter, r *http.Request) {
@@ -105,9 +667,10 @@ func deposit(w http.ResponseWriter, r *http.Request) {
 	}
 	accountID := parts[2]
 
-	account, exists := accounts[accountID]
-	if !exists {
-		w.WriteHeader(http.StatusNotFound)
+	principal := principalFromContext(r.Context())
+	if !canActOnAccount(principal, accountID) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "not authorized for this account"})
 		return
 	}
 
@@ -123,15 +686,63 @@ func deposit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	account.Balance += transaction.Amount
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		w.WriteHeader(http.StatusPreconditionRequired)
+		json.NewEncoder(w).Encode(map[string]string{"error": "If-Match header is required"})
+		return
+	}
+
+	nonce := requestNonce(r, transaction.Nonce)
+	if !requireNonce(w, nonce) {
+		return
+	}
+	var rec TransactionRecord
+	var replay *idempotentResponse
+	err := accountStore.Update(accountID, func(a *Account) error {
+		if etagFor(a.Version) != ifMatch {
+			return ErrVersionMismatch
+		}
+		if a.Frozen {
+			return ErrAccountFrozen
+		}
+		var appendErr error
+		rec, replay, appendErr = txStore.Append(accountID, "deposit", transaction.Amount, a.Currency, nonce)
+		if appendErr != nil {
+			return appendErr
+		}
+		a.Balance = rec.ResultingBalance
+		return nil
+	})
+	switch {
+	case err == ErrAccountNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case err == ErrVersionMismatch:
+		w.WriteHeader(http.StatusPreconditionFailed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "account has been modified since If-Match; refetch and retry"})
+		return
+	case err == ErrAccountFrozen:
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "account is frozen"})
+		return
+	case err == ErrNonceReplayed:
+		replayIdempotentResponse(w, replay)
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 
 	// Return updated account with HATEOAS links
-	accountCopy := *account
+	accountCopy, _ := accountStore.Get(accountID)
 	baseURL := fmt.Sprintf("http://%s", r.Host)
-	addHATEOASLinks(&accountCopy, baseURL)
+	addHATEOASLinks(&accountCopy, baseURL, principal)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(accountCopy)
+	w.Header().Set("ETag", etagFor(accountCopy.Version))
+	renderAndRecord(w, r, &accountCopy, func(contentType string, body []byte) {
+		txStore.RecordResponse(accountID, nonce, idempotentResponse{StatusCode: http.StatusOK, ContentType: contentType, Body: body})
+	})
 }
 
 func withdraw(w http.ResponseWriter, r *http.Request) {
@@ -147,16 +758,10 @@ func withdraw(w http.ResponseWriter, r *http.Request) {
 	}
 	accountID := parts[2]
 
-	account, exists := accounts[accountID]
-	if !exists {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-
-	// Check if withdraw is allowed based on current balance
-	if account.Balance <= 0 {
+	principal := principalFromContext(r.Context())
+	if !canActOnAccount(principal, accountID) {
 		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Withdrawal not allowed with negative balance"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "not authorized for this account"})
 		return
 	}
 
@@ -172,34 +777,345 @@ func withdraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	account.Balance -= transaction.Amount
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		w.WriteHeader(http.StatusPreconditionRequired)
+		json.NewEncoder(w).Encode(map[string]string{"error": "If-Match header is required"})
+		return
+	}
+
+	nonce := requestNonce(r, transaction.Nonce)
+	if !requireNonce(w, nonce) {
+		return
+	}
+	var rec TransactionRecord
+	var replay *idempotentResponse
+	err := accountStore.Update(accountID, func(a *Account) error {
+		if etagFor(a.Version) != ifMatch {
+			return ErrVersionMismatch
+		}
+		if a.Frozen {
+			return ErrAccountFrozen
+		}
+		if txStore.Balance(accountID) <= 0 {
+			return ErrInsufficientFunds
+		}
+		var appendErr error
+		rec, replay, appendErr = txStore.Append(accountID, "withdraw", transaction.Amount, a.Currency, nonce)
+		if appendErr != nil {
+			return appendErr
+		}
+		a.Balance = rec.ResultingBalance
+		return nil
+	})
+	switch {
+	case err == ErrAccountNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case err == ErrVersionMismatch:
+		w.WriteHeader(http.StatusPreconditionFailed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "account has been modified since If-Match; refetch and retry"})
+		return
+	case err == ErrAccountFrozen:
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "account is frozen"})
+		return
+	case err == ErrInsufficientFunds:
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Withdrawal not allowed with negative balance"})
+		return
+	case err == ErrNonceReplayed:
+		replayIdempotentResponse(w, replay)
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 
 	// Return updated account with HATEOAS links
-	accountCopy := *account
+	accountCopy, _ := accountStore.Get(accountID)
 	baseURL := fmt.Sprintf("http://%s", r.Host)
-	addHATEOASLinks(&accountCopy, baseURL)
+	addHATEOASLinks(&accountCopy, baseURL, principal)
+
+	w.Header().Set("ETag", etagFor(accountCopy.Version))
+	renderAndRecord(w, r, &accountCopy, func(contentType string, body []byte) {
+		txStore.RecordResponse(accountID, nonce, idempotentResponse{StatusCode: http.StatusOK, ContentType: contentType, Body: body})
+	})
+}
+
+func transfer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 3 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	sourceID := parts[2]
+
+	principal := principalFromContext(r.Context())
+	if !canActOnAccount(principal, sourceID) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "not authorized for this account"})
+		return
+	}
+
+	var xfer Transfer
+	if err := json.NewDecoder(r.Body).Decode(&xfer); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if xfer.Amount <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Amount must be positive"})
+		return
+	}
+
+	if xfer.ToAccountID == sourceID {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Cannot transfer to the same account"})
+		return
+	}
+
+	if _, err := accountStore.Get(sourceID); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if _, err := accountStore.Get(xfer.ToAccountID); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Destination account not found"})
+		return
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		w.WriteHeader(http.StatusPreconditionRequired)
+		json.NewEncoder(w).Encode(map[string]string{"error": "If-Match header is required"})
+		return
+	}
+
+	nonce := requestNonce(r, xfer.Nonce)
+	if !requireNonce(w, nonce) {
+		return
+	}
+	var debitRec, creditRec TransactionRecord
+	var replay *idempotentResponse
+	err := accountStore.UpdatePair(sourceID, xfer.ToAccountID, func(source, destination *Account) error {
+		if etagFor(source.Version) != ifMatch {
+			return ErrVersionMismatch
+		}
+		if source.Frozen {
+			return ErrAccountFrozen
+		}
+		if xfer.Currency != source.Currency || xfer.Currency != destination.Currency {
+			return ErrCurrencyMismatch
+		}
+		var transferErr error
+		debitRec, creditRec, replay, transferErr = txStore.Transfer(sourceID, xfer.ToAccountID, xfer.Amount, xfer.Currency, nonce)
+		if transferErr != nil {
+			return transferErr
+		}
+		source.Balance = debitRec.ResultingBalance
+		destination.Balance = creditRec.ResultingBalance
+		return nil
+	})
+	switch {
+	case err == ErrAccountNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case err == ErrVersionMismatch:
+		w.WriteHeader(http.StatusPreconditionFailed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "account has been modified since If-Match; refetch and retry"})
+		return
+	case err == ErrAccountFrozen:
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "account is frozen"})
+		return
+	case err == ErrCurrencyMismatch:
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Currency mismatch between accounts"})
+		return
+	case err == ErrNonceReplayed:
+		replayIdempotentResponse(w, replay)
+		return
+	case err == ErrInsufficientFunds:
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Transfer would leave source balance negative"})
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	sourceCopy, _ := accountStore.Get(sourceID)
+	destinationCopy, _ := accountStore.Get(xfer.ToAccountID)
+
+	baseURL := fmt.Sprintf("http://%s", r.Host)
+	addHATEOASLinks(&sourceCopy, baseURL, principal)
+	addHATEOASLinks(&destinationCopy, baseURL, principal)
+
+	result := &transferResult{
+		Links: map[string]Link{
+			"self": {Href: fmt.Sprintf("%s/accounts/%s/transfer", baseURL, sourceCopy.AccountID), Method: "POST", Rel: "self"},
+		},
+		Embedded: transferResultEmbed{
+			Source:      &sourceCopy,
+			Destination: &destinationCopy,
+		},
+	}
+
+	w.Header().Set("ETag", etagFor(sourceCopy.Version))
+	renderAndRecord(w, r, result, func(contentType string, body []byte) {
+		txStore.RecordResponse(sourceID, nonce, idempotentResponse{StatusCode: http.StatusOK, ContentType: contentType, Body: body})
+	})
+}
+
+func transactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 3 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	accountID := parts[2]
+
+	if _, err := accountStore.Get(accountID); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	cursor := query.Get("cursor")
+
+	records, next, prev, err := txStore.List(accountID, limit, cursor)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	baseURL := fmt.Sprintf("http://%s", r.Host)
+	selfHref := fmt.Sprintf("%s/accounts/%s/transactions", baseURL, accountID)
+
+	links := map[string]Link{
+		"self":    {Href: selfHref, Method: "GET", Rel: "self"},
+		"account": {Href: fmt.Sprintf("%s/accounts/%s", baseURL, accountID), Method: "GET", Rel: "account"},
+	}
+	if next != "" {
+		links["next"] = Link{Href: fmt.Sprintf("%s?cursor=%s", selfHref, url.QueryEscape(next)), Method: "GET", Rel: "next"}
+	}
+	if prev != "" {
+		links["prev"] = Link{Href: fmt.Sprintf("%s?cursor=%s", selfHref, url.QueryEscape(prev)), Method: "GET", Rel: "prev"}
+	} else if cursor != "" {
+		links["prev"] = Link{Href: selfHref, Method: "GET", Rel: "prev"}
+	}
+
+	collection := &transactionCollection{
+		Links:    links,
+		Embedded: transactionCollectionEmbed{Transactions: records},
+	}
+
+	renderRepresenter(w, r, collection)
+}
+
+// setFrozen backs the treasury-only freeze/unfreeze endpoints.
+func setFrozen(w http.ResponseWriter, r *http.Request, frozen bool) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 3 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	accountID := parts[2]
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		w.WriteHeader(http.StatusPreconditionRequired)
+		json.NewEncoder(w).Encode(map[string]string{"error": "If-Match header is required"})
+		return
+	}
+
+	err := accountStore.Update(accountID, func(a *Account) error {
+		if etagFor(a.Version) != ifMatch {
+			return ErrVersionMismatch
+		}
+		a.Frozen = frozen
+		return nil
+	})
+	switch {
+	case err == ErrAccountNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case err == ErrVersionMismatch:
+		w.WriteHeader(http.StatusPreconditionFailed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "account has been modified since If-Match; refetch and retry"})
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	accountCopy, _ := accountStore.Get(accountID)
+	accountCopy.Balance = txStore.Balance(accountID)
+	baseURL := fmt.Sprintf("http://%s", r.Host)
+	addHATEOASLinks(&accountCopy, baseURL, principalFromContext(r.Context()))
+
+	w.Header().Set("ETag", etagFor(accountCopy.Version))
+	renderRepresenter(w, r, &accountCopy)
+}
+
+func freeze(w http.ResponseWriter, r *http.Request) {
+	setFrozen(w, r, true)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(accountCopy)
+func unfreeze(w http.ResponseWriter, r *http.Request) {
+	setFrozen(w, r, false)
 }
 
 func main() {
+	http.HandleFunc("/accounts", requireRole("treasury", getAccounts))
 	http.HandleFunc("/accounts/", func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 
 		if strings.HasSuffix(path, "/deposit") {
-			deposit(w, r)
+			requireAuth(deposit)(w, r)
 		} else if strings.HasSuffix(path, "/withdraw") {
-			withdraw(w, r)
+			requireAuth(withdraw)(w, r)
+		} else if strings.HasSuffix(path, "/transfer") {
+			requireAuth(transfer)(w, r)
+		} else if strings.HasSuffix(path, "/freeze") {
+			requireRole("treasury", freeze)(w, r)
+		} else if strings.HasSuffix(path, "/unfreeze") {
+			requireRole("treasury", unfreeze)(w, r)
+		} else if strings.HasSuffix(path, "/transactions") {
+			transactions(w, r)
 		} else {
 			getAccount(w, r)
 		}
 	})
+	http.HandleFunc("/auth/token", authToken)
 
 	fmt.Println("HATEOAS Bank API server starting on :9001")
+	fmt.Println("Try: curl -X POST -d '{\"sub\": \"acc-123\", \"roles\": []}' http://localhost:9001/auth/token")
 	fmt.Println("Try: curl http://localhost:9001/accounts/acc-123")
 	fmt.Println("Try: curl http://localhost:9001/accounts/acc-456")
-	fmt.Println("Try: curl -X POST -H 'Content-Type: application/json' -d '{\"amount\": 100}' http://localhost:9001/accounts/acc-456/deposit")
+	fmt.Println("Try: curl -H 'Accept: text/html' -H 'Authorization: Bearer <token>' http://localhost:9001/accounts")
+	fmt.Println("Try: curl -X POST -H 'Authorization: Bearer <token>' -H 'If-Match: \"1\"' -H 'Idempotency-Key: <unique-key>' -H 'Content-Type: application/json' -d '{\"amount\": 100}' http://localhost:9001/accounts/acc-456/deposit")
+	fmt.Println("Try: curl -X POST -H 'Authorization: Bearer <token>' -H 'If-Match: \"1\"' -H 'Idempotency-Key: <unique-key>' -H 'Content-Type: application/json' -d '{\"toAccountId\": \"acc-456\", \"amount\": 100, \"currency\": \"USD\"}' http://localhost:9001/accounts/acc-123/transfer")
+	fmt.Println("Try: curl http://localhost:9001/accounts/acc-123/transactions")
 
 	err := http.ListenAndServe(":9001", http.DefaultServeMux)
 	if err != nil {
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func depositRequest(t *testing.T, accountID, ifMatch, nonce string, amount float64) *http.Request {
+	t.Helper()
+	body, err := json.Marshal(Transaction{Amount: amount})
+	if err != nil {
+		t.Fatalf("marshaling request body: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/accounts/"+accountID+"/deposit", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	if nonce != "" {
+		req.Header.Set("Idempotency-Key", nonce)
+	}
+	principal := &Principal{Subject: accountID}
+	return req.WithContext(withPrincipal(req.Context(), principal))
+}
+
+func TestDepositRequiresIfMatch(t *testing.T) {
+	rec := httptest.NewRecorder()
+	deposit(rec, depositRequest(t, "acc-123", "", "test-nonce-no-if-match", 10))
+	if rec.Code != http.StatusPreconditionRequired {
+		t.Errorf("status with no If-Match = %d, want %d", rec.Code, http.StatusPreconditionRequired)
+	}
+}
+
+func TestDepositRejectsStaleIfMatch(t *testing.T) {
+	rec := httptest.NewRecorder()
+	deposit(rec, depositRequest(t, "acc-123", `"not-the-current-version"`, "test-nonce-stale-etag", 10))
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("status with a stale If-Match = %d, want %d", rec.Code, http.StatusPreconditionFailed)
+	}
+}
+
+func TestDepositSucceedsWithCurrentIfMatchAndAdvancesETag(t *testing.T) {
+	before, err := accountStore.Get("acc-123")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	currentETag := etagFor(before.Version)
+
+	rec := httptest.NewRecorder()
+	deposit(rec, depositRequest(t, "acc-123", currentETag, "test-nonce-valid-etag", 10))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status with the current If-Match = %d, want %d; body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	gotETag := rec.Header().Get("ETag")
+	if gotETag == "" || gotETag == currentETag {
+		t.Errorf("ETag = %q, want a new value distinct from %q", gotETag, currentETag)
+	}
+
+	after, err := accountStore.Get("acc-123")
+	if err != nil {
+		t.Fatalf("Get after deposit: %v", err)
+	}
+	if after.Version != before.Version+1 {
+		t.Errorf("Version = %d, want %d", after.Version, before.Version+1)
+	}
+
+	// Replaying the same If-Match a second time must now fail: the
+	// account moved on and the ETag is stale again.
+	rec2 := httptest.NewRecorder()
+	deposit(rec2, depositRequest(t, "acc-123", currentETag, "test-nonce-valid-etag-replay", 10))
+	if rec2.Code != http.StatusPreconditionFailed {
+		t.Errorf("status reusing a now-stale If-Match = %d, want %d", rec2.Code, http.StatusPreconditionFailed)
+	}
+}
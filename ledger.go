@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TransactionRecord is one immutable entry in an account's ledger.
+type TransactionRecord struct {
+	ID               string    `json:"id"`
+	AccountID        string    `json:"accountId"`
+	Type             string    `json:"type"`
+	Amount           float64   `json:"amount"`
+	Currency         string    `json:"currency"`
+	Timestamp        time.Time `json:"timestamp"`
+	Nonce            string    `json:"nonce,omitempty"`
+	ResultingBalance float64   `json:"resultingBalance"`
+}
+
+// idempotentResponse is what gets replayed when a client resends a
+// nonce we've already recorded for the same account.
+type idempotentResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// nonceWindow is how long a nonce is remembered before it can be reused.
+const nonceWindow = 24 * time.Hour
+
+// ErrNonceReplayed is returned by TransactionStore.Append when the given
+// nonce was already recorded for the account within the idempotency window.
+var ErrNonceReplayed = errors.New("nonce already used for this account")
+
+// ErrInsufficientFunds is returned by TransactionStore.Transfer when the
+// source account's balance can't cover the transfer amount.
+var ErrInsufficientFunds = errors.New("source balance would go negative")
+
+// TransactionStore records every deposit/withdraw/transfer as an
+// immutable ledger entry and enforces per-account idempotency on
+// client-supplied nonces.
+type TransactionStore interface {
+	// Append records a new ledger entry for accountID. If nonce is
+	// non-empty and was already recorded for this account within the
+	// idempotency window, Append instead returns ErrNonceReplayed along
+	// with the response recorded for the original request (nil if the
+	// caller hadn't recorded one yet).
+	Append(accountID, txnType string, amount float64, currency, nonce string) (TransactionRecord, *idempotentResponse, error)
+
+	// Transfer atomically debits sourceID and credits destID under a
+	// single lock, so a concurrent reader never observes one side
+	// applied without the other. It returns ErrInsufficientFunds if the
+	// debit would take sourceID negative, checked and applied in the
+	// same critical section. The nonce is scoped to sourceID, the same
+	// as a plain Append.
+	Transfer(sourceID, destID string, amount float64, currency, nonce string) (debit, credit TransactionRecord, replay *idempotentResponse, err error)
+
+	// RecordResponse associates a completed HTTP response with a nonce
+	// already appended for accountID, so a replay can return it verbatim.
+	RecordResponse(accountID, nonce string, resp idempotentResponse)
+
+	// List returns up to limit entries for accountID newest-appended-last,
+	// starting after cursor (exclusive), plus cursors for the next and
+	// previous pages (empty when there is none).
+	List(accountID string, limit int, cursor string) (records []TransactionRecord, next string, prev string, err error)
+
+	// Balance returns the account's current balance as of the last
+	// recorded ledger entry, replaying from zero if there are none.
+	Balance(accountID string) float64
+
+	// HasEntries reports whether accountID already has at least one
+	// ledger entry, so callers seeding a hardcoded starting balance on
+	// startup can skip accounts a persistent store already knows about.
+	HasEntries(accountID string) bool
+}
+
+const defaultTransactionPageSize = 20
+
+type nonceEntry struct {
+	expiresAt time.Time
+	response  *idempotentResponse
+}
+
+// memoryTransactionStore is the default in-memory TransactionStore. A
+// SQLite-backed implementation lives in ledger_sqlite.go behind the
+// "sqlite" build tag.
+type memoryTransactionStore struct {
+	mu        sync.Mutex
+	seq       int
+	byAccount map[string][]TransactionRecord
+	nonces    map[string]nonceEntry
+}
+
+func NewMemoryTransactionStore() *memoryTransactionStore {
+	return &memoryTransactionStore{
+		byAccount: make(map[string][]TransactionRecord),
+		nonces:    make(map[string]nonceEntry),
+	}
+}
+
+func (s *memoryTransactionStore) Append(accountID, txnType string, amount float64, currency, nonce string) (TransactionRecord, *idempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if replay, replayed := s.checkNonceLocked(accountID, nonce); replayed {
+		return TransactionRecord{}, replay, ErrNonceReplayed
+	}
+
+	return s.appendLocked(accountID, txnType, amount, currency, nonce), nil, nil
+}
+
+func (s *memoryTransactionStore) Transfer(sourceID, destID string, amount float64, currency, nonce string) (TransactionRecord, TransactionRecord, *idempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if replay, replayed := s.checkNonceLocked(sourceID, nonce); replayed {
+		return TransactionRecord{}, TransactionRecord{}, replay, ErrNonceReplayed
+	}
+
+	if s.balanceLocked(sourceID)-amount < 0 {
+		return TransactionRecord{}, TransactionRecord{}, nil, ErrInsufficientFunds
+	}
+
+	debit := s.appendLocked(sourceID, "transfer-debit", amount, currency, nonce)
+	credit := s.appendLocked(destID, "transfer-credit", amount, currency, "")
+	return debit, credit, nil, nil
+}
+
+// checkNonceLocked reports whether nonce was already recorded for
+// accountID within the idempotency window, and the response to replay
+// if so. Callers must hold s.mu.
+func (s *memoryTransactionStore) checkNonceLocked(accountID, nonce string) (*idempotentResponse, bool) {
+	if nonce == "" {
+		return nil, false
+	}
+	entry, ok := s.nonces[nonceKey(accountID, nonce)]
+	if !ok || !time.Now().Before(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.response, true
+}
+
+// appendLocked records a new ledger entry for accountID. Callers must
+// hold s.mu.
+func (s *memoryTransactionStore) appendLocked(accountID, txnType string, amount float64, currency, nonce string) TransactionRecord {
+	s.seq++
+	balance := s.balanceLocked(accountID)
+	switch txnType {
+	case "deposit", "transfer-credit":
+		balance += amount
+	case "withdraw", "transfer-debit":
+		balance -= amount
+	}
+
+	rec := TransactionRecord{
+		ID:               fmt.Sprintf("txn-%d", s.seq),
+		AccountID:        accountID,
+		Type:             txnType,
+		Amount:           amount,
+		Currency:         currency,
+		Timestamp:        time.Now(),
+		Nonce:            nonce,
+		ResultingBalance: balance,
+	}
+	s.byAccount[accountID] = append(s.byAccount[accountID], rec)
+
+	if nonce != "" {
+		s.nonces[nonceKey(accountID, nonce)] = nonceEntry{expiresAt: time.Now().Add(nonceWindow)}
+	}
+
+	return rec
+}
+
+func (s *memoryTransactionStore) RecordResponse(accountID, nonce string, resp idempotentResponse) {
+	if nonce == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := nonceKey(accountID, nonce)
+	entry := s.nonces[key]
+	entry.response = &resp
+	s.nonces[key] = entry
+}
+
+func (s *memoryTransactionStore) List(accountID string, limit int, cursor string) ([]TransactionRecord, string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := s.byAccount[accountID]
+	start := 0
+	if cursor != "" {
+		for i, rec := range all {
+			if rec.ID == cursor {
+				start = i + 1
+				break
+			}
+		}
+	}
+	if limit <= 0 {
+		limit = defaultTransactionPageSize
+	}
+	end := start + limit
+	if end > len(all) {
+		end = len(all)
+	}
+	page := append([]TransactionRecord(nil), all[start:end]...)
+
+	var next, prev string
+	if end < len(all) {
+		next = all[end-1].ID
+	}
+	if start > 0 {
+		prevStart := start - limit
+		if prevStart < 0 {
+			prevStart = 0
+		}
+		if prevStart > 0 {
+			prev = all[prevStart-1].ID
+		}
+	}
+	return page, next, prev, nil
+}
+
+func (s *memoryTransactionStore) Balance(accountID string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.balanceLocked(accountID)
+}
+
+func (s *memoryTransactionStore) balanceLocked(accountID string) float64 {
+	records := s.byAccount[accountID]
+	if len(records) == 0 {
+		return 0
+	}
+	return records[len(records)-1].ResultingBalance
+}
+
+func (s *memoryTransactionStore) HasEntries(accountID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.byAccount[accountID]) > 0
+}
+
+func nonceKey(accountID, nonce string) string {
+	return accountID + "|" + nonce
+}
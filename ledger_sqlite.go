@@ -0,0 +1,299 @@
+//go:build sqlite
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteTransactionStore is a SQLite-backed TransactionStore, selected at
+// build time with `go build -tags sqlite`. It keeps the same semantics as
+// memoryTransactionStore but persists the ledger to disk.
+type sqliteTransactionStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteTransactionStore opens (creating if necessary) a SQLite
+// database at path and ensures the ledger schema exists.
+func NewSQLiteTransactionStore(path string) (*sqliteTransactionStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("opening ledger database: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS transactions (
+			id                TEXT PRIMARY KEY,
+			account_id        TEXT NOT NULL,
+			type              TEXT NOT NULL,
+			amount            REAL NOT NULL,
+			currency          TEXT NOT NULL,
+			timestamp         DATETIME NOT NULL,
+			nonce             TEXT,
+			resulting_balance REAL NOT NULL,
+			seq               INTEGER NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_transactions_account_seq ON transactions(account_id, seq);
+		CREATE TABLE IF NOT EXISTS idempotency_keys (
+			account_id   TEXT NOT NULL,
+			nonce        TEXT NOT NULL,
+			expires_at   DATETIME NOT NULL,
+			status_code  INTEGER,
+			content_type TEXT,
+			body         BLOB,
+			PRIMARY KEY (account_id, nonce)
+		);
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("creating ledger schema: %w", err)
+	}
+
+	return &sqliteTransactionStore{db: db}, nil
+}
+
+func (s *sqliteTransactionStore) Append(accountID, txnType string, amount float64, currency, nonce string) (TransactionRecord, *idempotentResponse, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return TransactionRecord{}, nil, err
+	}
+	defer tx.Rollback()
+
+	if replay, replayed, err := checkNonceTx(tx, accountID, nonce); err != nil {
+		return TransactionRecord{}, nil, err
+	} else if replayed {
+		return TransactionRecord{}, replay, ErrNonceReplayed
+	}
+
+	rec, err := appendTx(tx, accountID, txnType, amount, currency, nonce)
+	if err != nil {
+		return TransactionRecord{}, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return TransactionRecord{}, nil, err
+	}
+	return rec, nil, nil
+}
+
+func (s *sqliteTransactionStore) Transfer(sourceID, destID string, amount float64, currency, nonce string) (TransactionRecord, TransactionRecord, *idempotentResponse, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return TransactionRecord{}, TransactionRecord{}, nil, err
+	}
+	defer tx.Rollback()
+
+	if replay, replayed, err := checkNonceTx(tx, sourceID, nonce); err != nil {
+		return TransactionRecord{}, TransactionRecord{}, nil, err
+	} else if replayed {
+		return TransactionRecord{}, TransactionRecord{}, replay, ErrNonceReplayed
+	}
+
+	balance, err := balanceTxQuerier(tx, sourceID)
+	if err != nil {
+		return TransactionRecord{}, TransactionRecord{}, nil, err
+	}
+	if balance-amount < 0 {
+		return TransactionRecord{}, TransactionRecord{}, nil, ErrInsufficientFunds
+	}
+
+	debit, err := appendTx(tx, sourceID, "transfer-debit", amount, currency, nonce)
+	if err != nil {
+		return TransactionRecord{}, TransactionRecord{}, nil, err
+	}
+	credit, err := appendTx(tx, destID, "transfer-credit", amount, currency, "")
+	if err != nil {
+		return TransactionRecord{}, TransactionRecord{}, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return TransactionRecord{}, TransactionRecord{}, nil, err
+	}
+	return debit, credit, nil, nil
+}
+
+// checkNonceTx reports whether nonce was already recorded for
+// accountID within the idempotency window, and the response to replay
+// if so.
+func checkNonceTx(tx *sql.Tx, accountID, nonce string) (*idempotentResponse, bool, error) {
+	if nonce == "" {
+		return nil, false, nil
+	}
+	row := tx.QueryRow(
+		`SELECT expires_at, status_code, content_type, body FROM idempotency_keys WHERE account_id = ? AND nonce = ?`,
+		accountID, nonce,
+	)
+	var expiresAt time.Time
+	var statusCode sql.NullInt64
+	var contentType sql.NullString
+	var body []byte
+	if err := row.Scan(&expiresAt, &statusCode, &contentType, &body); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	if !time.Now().Before(expiresAt) {
+		return nil, false, nil
+	}
+	var replay *idempotentResponse
+	if statusCode.Valid {
+		replay = &idempotentResponse{StatusCode: int(statusCode.Int64), ContentType: contentType.String, Body: body}
+	}
+	return replay, true, nil
+}
+
+// appendTx records a new ledger entry for accountID within tx.
+func appendTx(tx *sql.Tx, accountID, txnType string, amount float64, currency, nonce string) (TransactionRecord, error) {
+	balance, err := balanceTxQuerier(tx, accountID)
+	if err != nil {
+		return TransactionRecord{}, err
+	}
+	switch txnType {
+	case "deposit", "transfer-credit":
+		balance += amount
+	case "withdraw", "transfer-debit":
+		balance -= amount
+	}
+
+	var seq int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM transactions`).Scan(&seq); err != nil {
+		return TransactionRecord{}, err
+	}
+	seq++
+
+	rec := TransactionRecord{
+		ID:               fmt.Sprintf("txn-%d", seq),
+		AccountID:        accountID,
+		Type:             txnType,
+		Amount:           amount,
+		Currency:         currency,
+		Timestamp:        time.Now(),
+		Nonce:            nonce,
+		ResultingBalance: balance,
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO transactions (id, account_id, type, amount, currency, timestamp, nonce, resulting_balance, seq)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.ID, rec.AccountID, rec.Type, rec.Amount, rec.Currency, rec.Timestamp, rec.Nonce, rec.ResultingBalance, seq,
+	)
+	if err != nil {
+		return TransactionRecord{}, err
+	}
+
+	if nonce != "" {
+		_, err = tx.Exec(
+			`INSERT INTO idempotency_keys (account_id, nonce, expires_at) VALUES (?, ?, ?)`,
+			accountID, nonce, time.Now().Add(nonceWindow),
+		)
+		if err != nil {
+			return TransactionRecord{}, err
+		}
+	}
+
+	return rec, nil
+}
+
+func (s *sqliteTransactionStore) RecordResponse(accountID, nonce string, resp idempotentResponse) {
+	if nonce == "" {
+		return
+	}
+	s.db.Exec(
+		`UPDATE idempotency_keys SET status_code = ?, content_type = ?, body = ? WHERE account_id = ? AND nonce = ?`,
+		resp.StatusCode, resp.ContentType, resp.Body, accountID, nonce,
+	)
+}
+
+func (s *sqliteTransactionStore) List(accountID string, limit int, cursor string) ([]TransactionRecord, string, string, error) {
+	if limit <= 0 {
+		limit = defaultTransactionPageSize
+	}
+
+	startSeq := 0
+	if cursor != "" {
+		if err := s.db.QueryRow(`SELECT seq FROM transactions WHERE id = ?`, cursor).Scan(&startSeq); err != nil {
+			return nil, "", "", err
+		}
+	}
+
+	rows, err := s.db.Query(
+		`SELECT id, account_id, type, amount, currency, timestamp, nonce, resulting_balance, seq
+		 FROM transactions WHERE account_id = ? AND seq > ? ORDER BY seq ASC LIMIT ?`,
+		accountID, startSeq, limit+1,
+	)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer rows.Close()
+
+	var records []TransactionRecord
+	var seqs []int
+	for rows.Next() {
+		var rec TransactionRecord
+		var seq int
+		var nonce sql.NullString
+		if err := rows.Scan(&rec.ID, &rec.AccountID, &rec.Type, &rec.Amount, &rec.Currency, &rec.Timestamp, &nonce, &rec.ResultingBalance, &seq); err != nil {
+			return nil, "", "", err
+		}
+		rec.Nonce = nonce.String
+		records = append(records, rec)
+		seqs = append(seqs, seq)
+	}
+
+	var next string
+	if len(records) > limit {
+		next = records[limit-1].ID
+		records = records[:limit]
+		seqs = seqs[:limit]
+	}
+
+	var prev string
+	if startSeq > 0 {
+		// seq is shared by every account, so the entry limit places
+		// before the cursor is counted among this account's rows.
+		var id string
+		if err := s.db.QueryRow(
+			`SELECT id FROM transactions WHERE account_id = ? AND seq < ? ORDER BY seq DESC LIMIT 1 OFFSET ?`,
+			accountID, startSeq, limit-1,
+		).Scan(&id); err == nil {
+			prev = id
+		}
+	}
+
+	return records, next, prev, nil
+}
+
+func (s *sqliteTransactionStore) Balance(accountID string) float64 {
+	balance, _ := balanceTxQuerier(s.db, accountID)
+	return balance
+}
+
+func (s *sqliteTransactionStore) HasEntries(accountID string) bool {
+	var exists int
+	err := s.db.QueryRow(`SELECT 1 FROM transactions WHERE account_id = ? LIMIT 1`, accountID).Scan(&exists)
+	return err == nil
+}
+
+// sqlQueryRower is satisfied by both *sql.DB and *sql.Tx.
+type sqlQueryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// balanceTxQuerier returns accountID's current balance as of the last
+// recorded ledger entry visible to q.
+func balanceTxQuerier(q sqlQueryRower, accountID string) (float64, error) {
+	var balance sql.NullFloat64
+	err := q.QueryRow(
+		`SELECT resulting_balance FROM transactions WHERE account_id = ? ORDER BY seq DESC LIMIT 1`,
+		accountID,
+	).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return balance.Float64, nil
+}
@@ -0,0 +1,10 @@
+//go:build !sqlite
+
+package main
+
+// newTxStore builds the default in-memory TransactionStore. Build with
+// `-tags sqlite` to persist the ledger to disk instead; see
+// ledger_store_sqlite.go.
+func newTxStore() TransactionStore {
+	return NewMemoryTransactionStore()
+}
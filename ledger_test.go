@@ -0,0 +1,138 @@
+package main
+
+import "testing"
+
+func TestMemoryTransactionStoreAppendTracksBalance(t *testing.T) {
+	s := NewMemoryTransactionStore()
+	rec, replay, err := s.Append("acc-1", "deposit", 100, "USD", "")
+	if err != nil || replay != nil {
+		t.Fatalf("Append: rec=%+v replay=%v err=%v", rec, replay, err)
+	}
+	if rec.ResultingBalance != 100 {
+		t.Errorf("ResultingBalance = %v, want 100", rec.ResultingBalance)
+	}
+	if s.Balance("acc-1") != 100 {
+		t.Errorf("Balance = %v, want 100", s.Balance("acc-1"))
+	}
+
+	rec, _, err = s.Append("acc-1", "withdraw", 40, "USD", "")
+	if err != nil {
+		t.Fatalf("Append withdraw: %v", err)
+	}
+	if rec.ResultingBalance != 60 {
+		t.Errorf("ResultingBalance after withdraw = %v, want 60", rec.ResultingBalance)
+	}
+}
+
+func TestMemoryTransactionStoreHasEntries(t *testing.T) {
+	s := NewMemoryTransactionStore()
+	if s.HasEntries("acc-1") {
+		t.Fatal("HasEntries = true before any Append")
+	}
+	s.Append("acc-1", "deposit", 10, "USD", "")
+	if !s.HasEntries("acc-1") {
+		t.Error("HasEntries = false after an Append")
+	}
+	if s.HasEntries("acc-2") {
+		t.Error("HasEntries = true for an untouched account")
+	}
+}
+
+// TestMemoryTransactionStoreNonceReplay covers the required-idempotency
+// contract: resending the same nonce for the same account must not
+// re-apply the transaction, and must return the previously recorded
+// response instead.
+func TestMemoryTransactionStoreNonceReplay(t *testing.T) {
+	s := NewMemoryTransactionStore()
+	rec, replay, err := s.Append("acc-1", "deposit", 100, "USD", "nonce-1")
+	if err != nil || replay != nil {
+		t.Fatalf("first Append: rec=%+v replay=%v err=%v", rec, replay, err)
+	}
+	s.RecordResponse("acc-1", "nonce-1", idempotentResponse{StatusCode: 200, Body: []byte(`{"ok":true}`)})
+
+	_, replay, err = s.Append("acc-1", "deposit", 100, "USD", "nonce-1")
+	if err != ErrNonceReplayed {
+		t.Fatalf("second Append with same nonce: err = %v, want ErrNonceReplayed", err)
+	}
+	if replay == nil || replay.StatusCode != 200 || string(replay.Body) != `{"ok":true}` {
+		t.Errorf("replay = %+v, want the recorded 200 response", replay)
+	}
+	if s.Balance("acc-1") != 100 {
+		t.Errorf("Balance = %v, want 100 (replay must not double-apply the deposit)", s.Balance("acc-1"))
+	}
+}
+
+func TestMemoryTransactionStoreNonceScopedPerAccount(t *testing.T) {
+	s := NewMemoryTransactionStore()
+	s.Append("acc-1", "deposit", 100, "USD", "shared-nonce")
+	// The same nonce on a different account is a distinct request, not
+	// a replay.
+	_, replay, err := s.Append("acc-2", "deposit", 50, "USD", "shared-nonce")
+	if err != nil || replay != nil {
+		t.Fatalf("Append on a different account with the same nonce: replay=%v err=%v", replay, err)
+	}
+	if s.Balance("acc-2") != 50 {
+		t.Errorf("Balance(acc-2) = %v, want 50", s.Balance("acc-2"))
+	}
+}
+
+func TestMemoryTransactionStoreTransferInsufficientFunds(t *testing.T) {
+	s := NewMemoryTransactionStore()
+	s.Append("acc-1", "deposit", 10, "USD", "")
+	_, _, _, err := s.Transfer("acc-1", "acc-2", 50, "USD", "")
+	if err != ErrInsufficientFunds {
+		t.Fatalf("Transfer err = %v, want ErrInsufficientFunds", err)
+	}
+	if s.Balance("acc-1") != 10 {
+		t.Errorf("Balance(acc-1) = %v, want 10 (rejected transfer must not mutate balance)", s.Balance("acc-1"))
+	}
+}
+
+func TestMemoryTransactionStoreTransferAppliesBothSides(t *testing.T) {
+	s := NewMemoryTransactionStore()
+	s.Append("acc-1", "deposit", 100, "USD", "")
+	debit, credit, replay, err := s.Transfer("acc-1", "acc-2", 30, "USD", "")
+	if err != nil || replay != nil {
+		t.Fatalf("Transfer: debit=%+v credit=%+v replay=%v err=%v", debit, credit, replay, err)
+	}
+	if debit.ResultingBalance != 70 {
+		t.Errorf("source ResultingBalance = %v, want 70", debit.ResultingBalance)
+	}
+	if credit.ResultingBalance != 30 {
+		t.Errorf("destination ResultingBalance = %v, want 30", credit.ResultingBalance)
+	}
+}
+
+func TestMemoryTransactionStoreListPagination(t *testing.T) {
+	s := NewMemoryTransactionStore()
+	for i := 0; i < 6; i++ {
+		s.Append("acc-1", "deposit", 1, "USD", "")
+	}
+
+	page0, next0, prev0, err := s.List("acc-1", 2, "")
+	if err != nil {
+		t.Fatalf("List page 0: %v", err)
+	}
+	if len(page0) != 2 || next0 == "" || prev0 != "" {
+		t.Fatalf("page 0 = len %d next %q prev %q, want len 2, a next cursor, no prev", len(page0), next0, prev0)
+	}
+
+	page1, next1, _, err := s.List("acc-1", 2, next0)
+	if err != nil {
+		t.Fatalf("List page 1: %v", err)
+	}
+	if len(page1) != 2 || next1 == "" {
+		t.Fatalf("page 1 = len %d next %q, want len 2 and a next cursor", len(page1), next1)
+	}
+
+	page2, next2, prev2, err := s.List("acc-1", 2, next1)
+	if err != nil {
+		t.Fatalf("List page 2: %v", err)
+	}
+	if len(page2) != 2 || next2 != "" {
+		t.Fatalf("page 2 = len %d next %q, want the final 2 records and no next cursor", len(page2), next2)
+	}
+	if prev2 == "" {
+		t.Error("expected a prev cursor on the last page of a 3-page listing")
+	}
+}